@@ -79,12 +79,13 @@ func usage() {
 	fmt.Printf(`%s is a utility to annotate tasks.
 
 Usage:
-%s [-f file] [-h] [-i] workspace
+%s [-f file] [-h] [-i] [-sort keys] workspace
 
 Flags:
     -f                       Set annotations using a file.
     -h                       Print this usage message.
     -i                       Initialise a new workspace if needed.
+    -sort keys               Sort tasks by the given keys.
 
 %s can either read annotations from standard input or from file.
 
@@ -100,16 +101,20 @@ annotations. For example,
 > annotation2 contains another note.
 
 will be read as two separate notes.
-`, name, name, name)
+
+%s
+`, name, name, name, workspace.SortUsage)
 }
 
 func main() {
 	var shouldInit bool
 	var fromFile string
+	var sortSpec string
 
 	flag.Usage = usage
 	flag.StringVar(&fromFile, "f", "", "Read annotations from a file.")
 	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
+	flag.StringVar(&sortSpec, "sort", "", "Sort tasks by the given keys.")
 	flag.Parse()
 
 	if flag.NArg() == 0 {
@@ -121,14 +126,17 @@ func main() {
 
 	var c *workspace.FilterChain
 	if flag.NArg() == 1 {
-		c, err = workspace.ProcessQuery([]string{}, workspace.StatusUncompleted)
+		c, err = ws.Query([]string{}, workspace.StatusUncompleted)
 	} else {
-		c, err = workspace.ProcessQuery(flag.Args()[1:], workspace.StatusUncompleted)
+		c, err = ws.Query(flag.Args()[1:], workspace.StatusUncompleted)
 	}
 	die.If(err)
 
 	entryID := ws.NewEntry()
-	tasks := c.Filter(ws.EntryTasks(entryID)).Sort()
+	sorter, err := workspace.LoadEntrySort(sortSpec)
+	die.If(err)
+	sorter = sorter.WithOrder(ws.Entries[entryID].Tasks)
+	tasks := c.Filter(ws.EntryTasks(entryID)).SortBy(sorter)
 	fmt.Println("Today's TODO:")
 	for i, task := range tasks {
 		fmt.Println(i, task)
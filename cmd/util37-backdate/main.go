@@ -19,12 +19,14 @@ func usage() {
 	fmt.Printf(`%s is a utility to backdate tasks.
 
 Usage:
-%s [-h] [-i] workspace
+%s [-h] [-i] [-sort keys] workspace
 
 Flags:
     -h                       Print this usage message.
+    -sort keys               Sort tasks by the given keys.
 
-`, name, name)
+%s
+`, name, name, workspace.SortUsage)
 }
 
 var stdin = bufio.NewReader(os.Stdin)
@@ -38,9 +40,11 @@ func readline() string {
 
 func main() {
 	var shouldInit bool
+	var sortSpec string
 
 	flag.Usage = usage
 	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
+	flag.StringVar(&sortSpec, "sort", "", "Sort tasks by the given keys.")
 	flag.Parse()
 
 	if flag.NArg() == 0 {
@@ -52,16 +56,19 @@ func main() {
 
 	var c *workspace.FilterChain
 	if flag.NArg() == 1 {
-		c, err = workspace.ProcessQuery([]string{}, workspace.StatusUncompleted)
+		c, err = ws.Query([]string{}, workspace.StatusUncompleted)
 	} else {
-		c, err = workspace.ProcessQuery(flag.Args()[1:], workspace.StatusUncompleted)
+		c, err = ws.Query(flag.Args()[1:], workspace.StatusUncompleted)
 	}
 	die.If(err)
 
 	entryID := ws.NewEntry()
-	tasks := c.Filter(ws.EntryTasks(entryID)).Sort()
+	sorter, err := workspace.LoadEntrySort(sortSpec)
+	die.If(err)
+	sorter = sorter.WithOrder(ws.Entries[entryID].Tasks)
+	tasks := c.Filter(ws.EntryTasks(entryID)).SortBy(sorter)
 	fmt.Printf("TODO %s (%d tasks):\n",
-		workspace.Today().Format(workspace.DateFormat),
+		ws.Today().Format(workspace.DateFormat),
 		len(tasks))
 	for i, task := range tasks {
 		fmt.Println(i, task)
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to chart a workspace's history.
+
+Usage:
+%s [-ascii] [-h] [-height rows] [-w width] workspace chart [query...]
+
+Flags:
+    -ascii                   Draw with ASCII characters only.
+    -h                       Print this usage message.
+    -height rows             The height of the burndown and flow charts
+                             (default 12).
+    -w width                 The width of the chart; by default, the
+                             COLUMNS environment variable is used, or
+                             80 columns if it isn't set.
+
+Charts:
+    burndown                 The number of open tasks each day.
+    flow                     Cumulative flow: completed tasks stacked
+                             beneath open tasks, each day.
+    heatmap                  Tasks completed each day, as a calendar
+                             with a column for each week.
+
+The charts cover as many days (or weeks, for the heatmap) as fit in
+the width, ending today; a from:, to: or last: in the query selects the
+range instead.
+
+The query should follow the filter language:
+%s
+`, name, name, workspace.FilterUsage)
+}
+
+type glyphs struct {
+	full, open string
+	shades     []string
+}
+
+var unicodeGlyphs = glyphs{
+	full:   "█",
+	open:   "░",
+	shades: []string{"·", "░", "▒", "▓", "█"},
+}
+
+var asciiGlyphs = glyphs{
+	full:   "#",
+	open:   "+",
+	shades: []string{".", ":", "o", "O", "@"},
+}
+
+func termWidth() int {
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		return cols
+	}
+	return 80
+}
+
+// sample reduces the days to at most n columns, keeping the last day
+// of each group so that the final column is always today.
+func sample(days []workspace.DayCounts, n int) []workspace.DayCounts {
+	if len(days) <= n {
+		return days
+	}
+
+	step := (len(days) + n - 1) / n
+	var out []workspace.DayCounts
+	for i := len(days) - 1; i >= 0; i -= step {
+		out = append([]workspace.DayCounts{days[i]}, out...)
+	}
+	return out
+}
+
+// plot draws stacked columns; lower and upper give the heights of the
+// two parts of each column.
+func plot(days []workspace.DayCounts, lower, upper func(workspace.DayCounts) int, height int, g glyphs) {
+	var max int
+	for _, d := range days {
+		if v := lower(d) + upper(d); v > max {
+			max = v
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+
+	label := len(strconv.Itoa(max))
+	scale := func(v int) int {
+		return (v*height + max/2) / max
+	}
+
+	for row := height; row > 0; row-- {
+		axis := strings.Repeat(" ", label)
+		if row == height {
+			axis = fmt.Sprintf("%*d", label, max)
+		}
+
+		var line strings.Builder
+		for _, d := range days {
+			lo := scale(lower(d))
+			hi := scale(lower(d) + upper(d))
+			switch {
+			case row <= lo:
+				line.WriteString(g.full)
+			case row <= hi:
+				line.WriteString(g.open)
+			default:
+				line.WriteString(" ")
+			}
+		}
+		fmt.Printf("%s |%s\n", axis, strings.TrimRight(line.String(), " "))
+	}
+
+	fmt.Printf("%*d +%s\n", label, 0, strings.Repeat("-", len(days)))
+	first := days[0].Date.Format(workspace.DateFormat)
+	last := days[len(days)-1].Date.Format(workspace.DateFormat)
+	gap := len(days) - len(first) - len(last)
+	if gap < 1 {
+		gap = 1
+	}
+	fmt.Printf("%s  %s%s%s\n", strings.Repeat(" ", label), first,
+		strings.Repeat(" ", gap), last)
+}
+
+func heatmap(days []workspace.DayCounts, g glyphs) {
+	var max int
+	for _, d := range days {
+		if d.Completed > max {
+			max = d.Completed
+		}
+	}
+
+	shade := func(n int) string {
+		if n == 0 || max == 0 {
+			return g.shades[0]
+		}
+		levels := len(g.shades) - 1
+		return g.shades[1+(n-1)*levels/max]
+	}
+
+	// Columns are weeks starting on Monday; pad the first week so
+	// that rows line up with weekdays.
+	start := workspace.PeriodStart(days[0].Date, "week")
+	offset := int(days[0].Date.Sub(start).Hours()/24 + 0.5)
+	weeks := (offset + len(days) + 6) / 7
+
+	months := []byte(strings.Repeat(" ", 4+2*weeks+2))
+	last := -4
+	for w := 0; w < weeks; w++ {
+		d := start.AddDate(0, 0, 7*w)
+		col := 4 + 2*w
+		if (w == 0 || d.Month() != d.AddDate(0, 0, -7).Month()) && col >= last+4 {
+			copy(months[col:], d.Format("Jan"))
+			last = col
+		}
+	}
+	fmt.Println(strings.TrimRight(string(months), " "))
+
+	for wd := 0; wd < 7; wd++ {
+		label := "   "
+		if wd%2 == 0 {
+			label = start.AddDate(0, 0, wd).Format("Mon")
+		}
+
+		var line strings.Builder
+		for w := 0; w < weeks; w++ {
+			i := 7*w + wd - offset
+			if i < 0 || i >= len(days) {
+				line.WriteString("  ")
+				continue
+			}
+			line.WriteString(shade(days[i].Completed) + " ")
+		}
+		fmt.Printf("%s %s\n", label, strings.TrimRight(line.String(), " "))
+	}
+
+	var total int
+	for _, d := range days {
+		total += d.Completed
+	}
+	fmt.Printf("\n%d tasks completed from %s to %s; darkest is %d in a day.\n",
+		total, days[0].Date.Format(workspace.DateFormat),
+		days[len(days)-1].Date.Format(workspace.DateFormat), max)
+}
+
+func main() {
+	var ascii bool
+	var width, height int
+
+	flag.Usage = usage
+	flag.BoolVar(&ascii, "ascii", false, "Draw with ASCII characters only.")
+	flag.IntVar(&height, "height", 12, "Height of the chart.")
+	flag.IntVar(&width, "w", termWidth(), "Width of the chart.")
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	g := unicodeGlyphs
+	if ascii {
+		g = asciiGlyphs
+	}
+
+	if height < 1 {
+		die.With("The chart must be at least one row high.")
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), false)
+	die.If(err)
+
+	words, err := ws.ExpandQuery(flag.Args()[2:])
+	die.If(err)
+
+	// The time filters would select tasks on their completion
+	// dates, hiding the open tasks, so they set the chart's range
+	// instead.
+	dates, words := workspace.SplitRange(words)
+	r, err := workspace.ProcessQuery(dates, workspace.StatusAny)
+	die.If(err)
+	c, err := workspace.ProcessQuery(words, workspace.StatusAny)
+	die.If(err)
+
+	chart := flag.Arg(1)
+	var columns int
+	switch chart {
+	case "burndown", "flow":
+		columns = width - 10
+	case "heatmap":
+		columns = 7 * ((width - 4) / 2)
+	default:
+		die.With("Unknown chart %s.", chart)
+	}
+	if columns < 7 {
+		die.With("The terminal is too narrow.")
+	}
+
+	end := r.End()
+	if end.IsZero() {
+		end = ws.Today()
+	}
+
+	start := r.Start()
+	if start.IsZero() {
+		start = end.AddDate(0, 0, -(columns - 1))
+		if chart == "heatmap" {
+			start = workspace.PeriodStart(start, "week").AddDate(0, 0, 7)
+		}
+	}
+
+	days := ws.History(c.Filter(ws.Tasks), start, end)
+	if len(days) == 0 {
+		die.With("No days in the range %s.", r.TimeRange())
+	}
+
+	switch chart {
+	case "burndown":
+		fmt.Println("Open tasks")
+		plot(sample(days, columns), func(d workspace.DayCounts) int {
+			return d.Open
+		}, func(workspace.DayCounts) int { return 0 }, height, g)
+	case "flow":
+		fmt.Printf("Cumulative flow (%s completed, %s open)\n", g.full, g.open)
+		plot(sample(days, columns), func(d workspace.DayCounts) int {
+			return d.Done
+		}, func(d workspace.DayCounts) int {
+			return d.Open
+		}, height, g)
+	case "heatmap":
+		if len(days) > columns {
+			days = days[len(days)-columns:]
+		}
+		heatmap(days, g)
+	}
+}
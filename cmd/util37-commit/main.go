@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to complete tasks from git commit messages.
+
+Usage:
+%s [-C dir] [-c commit] [-h] [-n] workspace [message-file]
+
+Flags:
+    -C dir                   Run git in dir instead of the current
+                             directory.
+    -c commit                Read the message of the given commit
+                             (default HEAD).
+    -h                       Print this usage message.
+    -n                       Show what would change without changing
+                             the workspace.
+
+%s reads a commit message and completes the tasks it names in
+trailers at the end of the message, such as
+
+    Completes: util37#1438560000000000000
+
+Several tasks may be given in one trailer, separated by commas. Each
+task is marked as completed and annotated with the commit's hash and
+subject. Running %s again for the same commit changes nothing, and
+the annotation made by a commit-msg hook is replaced by the one with
+the hash when the commit is read again after it has been made.
+
+If a message file is given, as git passes to a commit-msg hook, the
+message is read from it; the commit hasn't been made yet, so the
+annotation has no hash. Otherwise the commit is read from the local
+repository with git log, as in a post-commit hook:
+
+    #!/bin/sh
+    # .git/hooks/post-commit
+    %s work
+
+%s exits with a non-zero status, without changing the workspace, if
+a task named isn't in it, which makes a commit-msg hook reject the
+commit.
+`, name, name, name, name, name, name)
+}
+
+// readCommit returns the hash and message of a commit in the local
+// repository.
+func readCommit(dir, rev string) (string, string, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%H%n%B", rev, "--")
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git log failed: %v", err)
+	}
+
+	parts := strings.SplitN(string(out), "\n", 2)
+	if len(parts) < 2 {
+		return parts[0], "", nil
+	}
+	return parts[0], parts[1], nil
+}
+
+func main() {
+	var dir, rev string
+	var dryRun bool
+
+	flag.Usage = usage
+	flag.StringVar(&dir, "C", "", "Run git in the given directory.")
+	flag.StringVar(&rev, "c", "HEAD", "Read the given commit.")
+	flag.BoolVar(&dryRun, "n", false, "Show what would change.")
+	flag.Parse()
+
+	if flag.NArg() == 0 || flag.NArg() > 2 {
+		die.With("Workspace name is required.")
+	}
+
+	var hash, msg string
+	if flag.NArg() == 2 {
+		in, err := ioutil.ReadFile(flag.Arg(1))
+		die.If(err)
+		msg = string(in)
+	} else {
+		var err error
+		hash, msg, err = readCommit(dir, rev)
+		die.If(err)
+	}
+
+	ids, err := workspace.CompletedIDs(msg)
+	die.If(err)
+	if len(ids) == 0 {
+		return
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), false)
+	die.If(err)
+
+	tasks, err := ws.CompleteCommit(ids, hash, workspace.CommitSubject(msg))
+	die.If(err)
+	for _, task := range tasks {
+		fmt.Println("Completed:", task)
+	}
+
+	if dryRun {
+		fmt.Println("Dry run; the workspace was not changed.")
+	} else if len(tasks) > 0 {
+		err = workspace.WriteFile(ws)
+		die.If(err)
+	}
+}
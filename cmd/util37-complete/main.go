@@ -18,17 +18,19 @@ func usage() {
 	fmt.Printf(`%s is a utility to mark tasks as completed.
 
 Usage:
-%s [-h] [-i] workspace
+%s [-h] [-i] [-sort keys] workspace
 
 Flags:
     -h                       Print this usage message.
     -i                       Initialise a new workspace if needed.
+    -sort keys               Sort tasks by the given keys.
 
 When run, %s will display the numbered current list of tasks,
 both completed and unfinished. A one-line task title should be entered, or
 an empty line to exit. This cycle will repeat until an empty line is entered.
 
-`, name, name, name)
+%s
+`, name, name, name, workspace.SortUsage)
 }
 
 var stdin = bufio.NewReader(os.Stdin)
@@ -42,9 +44,11 @@ func readline() string {
 
 func main() {
 	var shouldInit bool
+	var sortSpec string
 
 	flag.Usage = usage
 	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
+	flag.StringVar(&sortSpec, "sort", "", "Sort tasks by the given keys.")
 	flag.Parse()
 
 	if flag.NArg() == 0 {
@@ -56,16 +60,19 @@ func main() {
 
 	var c *workspace.FilterChain
 	if flag.NArg() == 1 {
-		c, err = workspace.ProcessQuery([]string{}, workspace.StatusUncompleted)
+		c, err = ws.Query([]string{}, workspace.StatusUncompleted)
 	} else {
-		c, err = workspace.ProcessQuery(flag.Args()[1:], workspace.StatusUncompleted)
+		c, err = ws.Query(flag.Args()[1:], workspace.StatusUncompleted)
 	}
 	die.If(err)
 
 	entryID := ws.NewEntry()
-	tasks := c.Filter(ws.EntryTasks(entryID)).Sort()
+	sorter, err := workspace.LoadEntrySort(sortSpec)
+	die.If(err)
+	sorter = sorter.WithOrder(ws.Entries[entryID].Tasks)
+	tasks := c.Filter(ws.EntryTasks(entryID)).SortBy(sorter)
 	fmt.Printf("TODO %s (%d tasks):\n",
-		workspace.Today().Format(workspace.DateFormat),
+		ws.Today().Format(workspace.DateFormat),
 		len(tasks))
 	for i, task := range tasks {
 		fmt.Println(i, task)
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to view and change a workspace's settings.
+
+Usage:
+%s [-h] [-i] workspace [setting [value]]
+
+Flags:
+    -h                       Print this usage message.
+    -i                       Initialise a new workspace if needed.
+
+With only a workspace, its settings are listed; with a setting, its
+value is shown; and with a value, the setting is changed.
+
+Settings:
+    daystart                 The hour, from 0 to 23, at which the day
+                             starts; work done before then belongs to
+                             the previous day's list (default 0).
+    timezone                 The IANA time zone days are counted in,
+                             such as Europe/London; empty for the local
+                             time zone.
+    backfill                 If true, lists are created for days on
+                             which the workspace wasn't used, so that
+                             looking back shows the tasks carried
+                             through them (default false).
+`, name, name)
+}
+
+var settings = []string{"daystart", "timezone", "backfill"}
+
+func show(ws *workspace.Workspace, setting string) {
+	switch setting {
+	case "daystart":
+		fmt.Println(ws.DayStart)
+	case "timezone":
+		fmt.Println(ws.TimeZone)
+	case "backfill":
+		fmt.Println(ws.Backfill)
+	default:
+		die.With("Unknown setting %s.", setting)
+	}
+}
+
+func set(ws *workspace.Workspace, setting, value string) error {
+	switch setting {
+	case "daystart":
+		hour, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		return ws.SetDayStart(hour)
+	case "timezone":
+		return ws.SetTimeZone(value)
+	case "backfill":
+		backfill, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		ws.Backfill = backfill
+		return nil
+	}
+
+	return fmt.Errorf("unknown setting %s", setting)
+}
+
+func main() {
+	var shouldInit bool
+
+	flag.Usage = usage
+	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		die.With("Workspace name is required.")
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), shouldInit)
+	die.If(err)
+
+	switch flag.NArg() {
+	case 1:
+		for _, setting := range settings {
+			fmt.Printf("%s: ", setting)
+			show(ws, setting)
+		}
+	case 2:
+		show(ws, flag.Arg(1))
+	case 3:
+		die.If(set(ws, flag.Arg(1), flag.Arg(2)))
+		err = workspace.WriteFile(ws)
+		die.If(err)
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to look back at previous days' tasks.
+
+Usage:
+%s [-format fmt] [-h] [-l] [-s] workspace [day [other-day]]
+
+Flags:
+    -format fmt              Select the output format: text, json or
+                             ndjson. JSON output lists the tasks as
+                             util37-today -format json does; when two
+                             days are compared, each task's "change"
+                             field says how it changed.
+    -h                       Print this usage message.
+    -l                       Print task annotations (long format).
+    -s                       Step through the days interactively.
+
+Days may be given as YYYY-MM-DD, "today", "yesterday", or a number of
+days ago, such as -3. With one day, the list of tasks for that day is
+shown, with each task marked as it stood at the end of the day. With
+two days, the lists are compared, showing the tasks added, completed,
+carried over and dropped between them. With no day, yesterday is
+shown.
+
+When stepping through days, enter p or n to move to the previous or
+next day with a list, d to compare the day with the previous one, or
+an empty line to exit.
+`, name, name)
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func readline() string {
+	line, err := stdin.ReadString('\n')
+	die.If(err)
+
+	return strings.TrimSpace(line)
+}
+
+func findEntry(ws *workspace.Workspace, day string) uint64 {
+	t, err := ws.ParseDay(day)
+	die.If(err)
+
+	id := ws.DateID(t)
+	if _, ok := ws.Entries[id]; !ok {
+		die.With("No list of tasks for %s.", t.Format(workspace.DateFormat))
+	}
+	return id
+}
+
+func show(ws *workspace.Workspace, id uint64, long bool) {
+	e := ws.Entries[id]
+	day := ws.EntryDay(id)
+	end := ws.DayEnd(day)
+
+	tasks := ws.EntryTasks(id).SortBy(&workspace.Sorter{
+		Keys:  []workspace.SortKey{{Field: workspace.SortOrder}},
+		Order: e.Tasks,
+	})
+
+	note := ""
+	if e.Backfilled {
+		note = ", not used that day"
+	}
+	fmt.Printf("TODO %s (%d tasks%s):\n", day.Format(workspace.DateFormat), len(tasks), note)
+	for _, task := range tasks {
+		fmt.Println("\t", task.AsOf(end))
+		if long {
+			for _, note := range task.Notes {
+				fmt.Println(workspace.Wrap("+ "+note, "\t\t", 72))
+			}
+		}
+	}
+}
+
+func diff(ws *workspace.Workspace, from, to uint64) {
+	if from > to {
+		from, to = to, from
+	}
+
+	d := ws.DiffEntries(from, to)
+	fmt.Printf("Changes from %s to %s:\n",
+		ws.EntryDay(from).Format(workspace.DateFormat),
+		ws.EntryDay(to).Format(workspace.DateFormat))
+
+	section := func(title string, tasks []*workspace.Task) {
+		fmt.Printf("%s (%d):\n", title, len(tasks))
+		for _, task := range tasks {
+			fmt.Println("\t", task)
+		}
+	}
+	section("Added", d.Added)
+	section("Completed", d.Completed)
+	section("Carried over", d.Carried)
+	if len(d.Dropped) > 0 {
+		section("Dropped", d.Dropped)
+	}
+}
+
+func step(ws *workspace.Workspace, id uint64, long bool) {
+	show(ws, id, long)
+	for {
+		fmt.Printf("(p)revious, (n)ext, (d)iff: ")
+		line := readline()
+
+		switch line {
+		case "":
+			return
+		case "p", "n":
+			dir := 1
+			if line == "p" {
+				dir = -1
+			}
+
+			next, ok := ws.AdjacentEntry(id, dir)
+			if !ok {
+				fmt.Println("No more days.")
+				continue
+			}
+			id = next
+			show(ws, id, long)
+		case "d":
+			prev, ok := ws.AdjacentEntry(id, -1)
+			if !ok {
+				fmt.Println("No previous day.")
+				continue
+			}
+			diff(ws, prev, id)
+		}
+	}
+}
+
+func main() {
+	var long, interactive bool
+	var format = workspace.FormatText
+
+	flag.Usage = usage
+	flag.StringVar(&format, "format", format, "Select the output format.")
+	flag.BoolVar(&long, "l", false, "Show annotations of each task.")
+	flag.BoolVar(&interactive, "s", false, "Step through the days.")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		die.With("Workspace name is required.")
+	}
+
+	switch format {
+	case workspace.FormatText:
+	case workspace.FormatJSON, workspace.FormatNDJSON:
+		if interactive {
+			die.With("Only text output can be stepped through.")
+		}
+	default:
+		die.With("Unsupported output format %s.", format)
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), false)
+	die.If(err)
+
+	switch flag.NArg() {
+	case 1, 2:
+		day := "yesterday"
+		if flag.NArg() == 2 {
+			day = flag.Arg(1)
+		}
+
+		id := findEntry(ws, day)
+		switch {
+		case format != workspace.FormatText:
+			die.If(ws.NewDayReport(id).Write(os.Stdout, format))
+		case interactive:
+			step(ws, id, long)
+		default:
+			show(ws, id, long)
+		}
+	case 3:
+		from := findEntry(ws, flag.Arg(1))
+		to := findEntry(ws, flag.Arg(2))
+		if format != workspace.FormatText {
+			die.If(ws.NewDiffReport(from, to).Write(os.Stdout, format))
+		} else {
+			diff(ws, from, to)
+		}
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to export tasks for use in other tools.
+
+Usage:
+%s [-columns list] [-format fmt] [-h] [-o file] [-sort keys] [-u]
+    workspace [query...]
+
+Flags:
+    -columns list            The columns to write in CSV exports, from
+                             id, title, status, priority, created,
+                             finished, days, due, tags and notes; the
+                             default is set by the CSVColumns key in
+                             the configuration file, or is
+                             %s.
+    -format fmt              Select the export format (default todotxt).
+    -h                       Print this usage message.
+    -o file                  Write to file instead of standard output.
+    -sort keys               Sort tasks by the given keys.
+    -u                       Only export unfinished tasks.
+
+Every task matching the query is exported, whether or not it has been
+completed, unless -u is given. Exported tasks keep their IDs, so
+importing them again with util37-import updates the tasks rather than
+duplicating them. Anything that the format can't represent exactly is
+listed on standard error.
+
+%s
+The query should follow the filter language:
+%s
+
+%s
+`, name, name, workspace.DefaultCSVColumns, workspace.ExchangeUsage, workspace.FilterUsage, workspace.SortUsage)
+}
+
+// writeOutput replaces the named file with data in one step, keeping
+// the file's permissions if it exists.
+func writeOutput(name string, data []byte) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(name); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func main() {
+	var format = workspace.FormatTodoTxt
+	var output, sortSpec string
+	var unfinished bool
+	var columns string
+
+	flag.Usage = usage
+	flag.StringVar(&columns, "columns", "", "Columns to write in CSV exports.")
+	flag.StringVar(&format, "format", format, "Select the export format.")
+	flag.StringVar(&output, "o", "", "Write to file instead of standard output.")
+	flag.StringVar(&sortSpec, "sort", "", "Sort tasks by the given keys.")
+	flag.BoolVar(&unfinished, "u", false, "Only export unfinished tasks.")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		die.With("Workspace name is required.")
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), false)
+	die.If(err)
+
+	status := workspace.StatusAny
+	if unfinished {
+		status = workspace.StatusUncompleted
+	}
+
+	c, err := ws.Query(flag.Args()[1:], status)
+	die.If(err)
+
+	sorter, err := workspace.LoadSort(sortSpec)
+	die.If(err)
+	tasks := c.Filter(ws.Tasks).SortBy(sorter)
+
+	// The tasks are written to a buffer first, so that the output
+	// file is left alone if the format or columns are invalid.
+	w := &bytes.Buffer{}
+	var unmapped workspace.Unmapped
+	switch format {
+	case workspace.FormatTodoTxt:
+		err = workspace.WriteTodoTxt(w, tasks)
+	case workspace.FormatTaskwarrior:
+		unmapped, err = workspace.WriteTaskwarrior(w, tasks)
+	case workspace.FormatICal:
+		err = workspace.WriteICal(w, tasks)
+	case workspace.FormatCSV:
+		var cols []string
+		cols, err = workspace.LoadCSVColumns(columns)
+		die.If(err)
+		err = workspace.WriteCSV(w, tasks, cols)
+	case workspace.FormatMarkdown:
+		err = workspace.WriteMarkdown(w, tasks)
+	case workspace.FormatOrg:
+		err = workspace.WriteOrg(w, tasks)
+	default:
+		die.With("Unknown export format %s.", format)
+	}
+	die.If(err)
+
+	if output == "" {
+		_, err = os.Stdout.Write(w.Bytes())
+	} else {
+		err = writeOutput(output, w.Bytes())
+	}
+	die.If(err)
+
+	for _, field := range unmapped.Strings() {
+		fmt.Fprintln(os.Stderr, "Not mapped exactly:", field)
+	}
+}
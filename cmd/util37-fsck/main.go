@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to check a workspace for consistency.
+
+Usage:
+%s [-f] [-h] workspace
+
+Flags:
+    -f                       Fix the problems found.
+    -h                       Print this usage message.
+
+%s checks that the workspace's tag index agrees with the tags on
+each task, and that every task listed in an entry exists. Problems
+are reported; with -f, the tag index is rebuilt from the tasks and
+missing tasks are removed from the entries.
+
+%s exits with a non-zero status if problems were found and not fixed.
+`, name, name, name, name)
+}
+
+func main() {
+	var fix bool
+
+	flag.Usage = usage
+	flag.BoolVar(&fix, "f", false, "Fix the problems found.")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		die.With("Workspace name is required.")
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), false)
+	die.If(err)
+
+	problems := ws.CheckTags()
+	problems = append(problems, ws.CheckEntries()...)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+
+	if len(problems) == 0 {
+		return
+	}
+
+	if !fix {
+		fmt.Printf("%d problems found.\n", len(problems))
+		os.Exit(1)
+	}
+
+	ws.RebuildTags()
+	ws.PruneEntries()
+	err = workspace.WriteFile(ws)
+	die.If(err)
+	fmt.Printf("Fixed %d problems.\n", len(problems))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to collect TODO comments from source code.
+
+Usage:
+%s [-h] [-i] [-n] [-p project] workspace [directory]
+
+Flags:
+    -h                       Print this usage message.
+    -i                       Initialise a new workspace if needed.
+    -n                       Show what would change without changing
+                             the workspace.
+    -p project               Name the project the comments belong to;
+                             the default is the directory's path under
+                             the home directory, or its absolute path.
+
+%s walks the directory, or the current directory if none is
+given, and finds TODO, FIXME and XXX comments. Each comment becomes a
+task titled with the comment's text, tagged with its kind (e.g. todo)
+and with the project and file it was found in, as project/path; the
+comment's file and line are kept in a note. FIXME and XXX comments
+are given a high priority.
+
+Comments are identified by a fingerprint of their file, kind and
+text, so a comment that moves within its file updates the same task.
+Tasks whose comments are no longer found in the project are marked as
+completed; the tasks of other projects, including those whose names
+start with this one's, are left alone. Hidden directories, vendor, node_modules and testdata are
+skipped, as are binary files.
+`, name, name, name)
+}
+
+// defaultProject names a directory's project by its path, so that two
+// checkouts with the same name don't complete each other's tasks.
+func defaultProject(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		rel, err := filepath.Rel(home, abs)
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			abs = rel
+		}
+	}
+
+	return strings.TrimPrefix(filepath.ToSlash(abs), "/"), nil
+}
+
+func main() {
+	var shouldInit, dryRun bool
+	var project string
+
+	flag.Usage = usage
+	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
+	flag.BoolVar(&dryRun, "n", false, "Show what would change.")
+	flag.StringVar(&project, "p", "", "Name the project.")
+	flag.Parse()
+
+	if flag.NArg() == 0 || flag.NArg() > 2 {
+		die.With("Workspace name is required.")
+	}
+
+	dir := "."
+	if flag.NArg() == 2 {
+		dir = flag.Arg(1)
+	}
+
+	if project == "" {
+		var err error
+		project, err = defaultProject(dir)
+		die.If(err)
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), shouldInit)
+	die.If(err)
+
+	comments, err := workspace.ScanComments(dir)
+	die.If(err)
+
+	result := ws.Harvest(project, comments)
+	for _, task := range result.Added {
+		fmt.Println("Added:    ", task)
+	}
+
+	for _, task := range result.Updated {
+		fmt.Println("Updated:  ", task)
+	}
+
+	for _, task := range result.Completed {
+		fmt.Println("Completed:", task)
+	}
+
+	fmt.Printf("%d comments: %d added, %d updated, %d completed.\n",
+		len(comments), len(result.Added), len(result.Updated),
+		len(result.Completed))
+	if dryRun {
+		fmt.Println("Dry run; the workspace was not changed.")
+		return
+	}
+
+	err = workspace.WriteFile(ws)
+	die.If(err)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to import tasks from other tools.
+
+Usage:
+%s [-format fmt] [-h] [-i] [-map mapping] [-n] workspace [file]
+
+Flags:
+    -format fmt              Select the import format (default todotxt).
+    -h                       Print this usage message.
+    -i                       Initialise a new workspace if needed.
+    -map mapping             Map the headers of a CSV file to columns,
+                             as a list of header=column pairs, such as
+                             "Task name=title,Labels=tags".
+    -n                       Show what would be imported without
+                             changing the workspace.
+
+Tasks are read from the file, or from standard input if no file is
+given. A task with the ID of an existing task, or that was imported
+from the same task in another tool, updates it; other tasks are added.
+Unfinished tasks are put on today's list. Fields in the input that
+couldn't be converted are listed.
+
+The first row of a CSV file is a header naming the columns; headers
+are matched against the columns that util37-export writes, and common
+alternatives such as "task" or "summary" for the title, ignoring case.
+
+%s`, name, name, workspace.ExchangeUsage)
+}
+
+func main() {
+	var format = workspace.FormatTodoTxt
+	var shouldInit, dryRun bool
+	var mapSpec string
+
+	flag.Usage = usage
+	flag.StringVar(&format, "format", format, "Select the import format.")
+	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
+	flag.StringVar(&mapSpec, "map", "", "Map CSV headers to columns.")
+	flag.BoolVar(&dryRun, "n", false, "Show what would be imported.")
+	flag.Parse()
+
+	if flag.NArg() == 0 || flag.NArg() > 2 {
+		die.With("Workspace name is required.")
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), shouldInit)
+	die.If(err)
+
+	var r io.Reader = os.Stdin
+	if flag.NArg() == 2 {
+		f, err := os.Open(flag.Arg(1))
+		die.If(err)
+		defer f.Close()
+		r = f
+	}
+
+	var tasks []*workspace.Task
+	var parents workspace.Parents
+	var unmapped workspace.Unmapped
+	switch format {
+	case workspace.FormatTodoTxt:
+		tasks, err = ws.ReadTodoTxt(r)
+	case workspace.FormatTaskwarrior:
+		tasks, unmapped, err = ws.ReadTaskwarrior(r)
+	case workspace.FormatICal:
+		tasks, unmapped, err = ws.ReadICal(r)
+	case workspace.FormatCSV:
+		var mapping map[string]string
+		mapping, err = workspace.ParseCSVMapping(mapSpec)
+		die.If(err)
+		tasks, unmapped, err = ws.ReadCSV(r, mapping)
+	case workspace.FormatMarkdown:
+		tasks, parents, unmapped, err = ws.ReadMarkdown(r)
+	case workspace.FormatOrg:
+		tasks, parents, unmapped, err = ws.ReadOrg(r)
+	default:
+		die.With("Unknown import format %s.", format)
+	}
+	die.If(err)
+
+	for _, field := range unmapped.Strings() {
+		fmt.Println("Not mapped:", field)
+	}
+
+	result := ws.Import(tasks, parents)
+	for _, task := range result.Added {
+		fmt.Println("Added:  ", task)
+	}
+
+	for _, task := range result.Updated {
+		fmt.Println("Updated:", task)
+	}
+
+	fmt.Printf("%d added, %d updated, %d unchanged.\n",
+		len(result.Added), len(result.Updated), result.Unchanged)
+	if dryRun {
+		fmt.Println("Dry run; the workspace was not changed.")
+		return
+	}
+
+	err = workspace.WriteFile(ws)
+	die.If(err)
+}
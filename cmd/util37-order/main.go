@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to order today's tasks by hand.
+
+Usage:
+%s [-h] [-i] [-n focus] workspace [query...]
+
+Flags:
+    -h                       Print this usage message.
+    -i                       Initialise a new workspace if needed.
+    -n focus                 Set the number of tasks at the top of the
+                             list that are today's focus.
+
+When run, %s will display the numbered list of unfinished tasks in
+today's order, with the focus tasks marked by an asterisk. After
+selecting a task, it may be moved to the (t)op or (b)ottom of the list,
+(u)p or (d)own one place, or to the position of another task by
+entering its number. An empty line exits.
+
+The order and focus count are carried over to the next day.
+
+The query should follow the filter language:
+%s
+`, name, name, name, workspace.FilterUsage)
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func readline() string {
+	line, err := stdin.ReadString('\n')
+	die.If(err)
+
+	return strings.TrimSpace(line)
+}
+
+// target returns the entry position that the task at idx in the
+// displayed list should be moved to.
+func target(entry *workspace.Entry, tasks []*workspace.Task, idx int, move string) (int, bool) {
+	switch move {
+	case "t", "top":
+		return 0, true
+	case "b", "bottom":
+		return len(entry.Tasks) - 1, true
+	case "u", "up":
+		if idx == 0 {
+			return 0, false
+		}
+		return entry.Index(tasks[idx-1].ID), true
+	case "d", "down":
+		if idx == len(tasks)-1 {
+			return 0, false
+		}
+		return entry.Index(tasks[idx+1].ID), true
+	}
+
+	pos, err := strconv.Atoi(move)
+	if err != nil || pos < 0 || pos >= len(tasks) {
+		return 0, false
+	}
+	return entry.Index(tasks[pos].ID), true
+}
+
+func main() {
+	var shouldInit bool
+	var focus int
+
+	flag.Usage = usage
+	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
+	flag.IntVar(&focus, "n", -1, "Set the number of focus tasks.")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		die.With("Workspace name is required.")
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), shouldInit)
+	die.If(err)
+
+	var c *workspace.FilterChain
+	if flag.NArg() == 1 {
+		c, err = ws.Query([]string{}, workspace.StatusUncompleted)
+	} else {
+		c, err = ws.Query(flag.Args()[1:], workspace.StatusUncompleted)
+	}
+	die.If(err)
+
+	entryID := ws.NewEntry()
+	entry := ws.Entries[entryID]
+	if focus >= 0 {
+		entry.Focus = focus
+		err = workspace.WriteFile(ws)
+		die.If(err)
+	}
+
+	sorter, err := workspace.ParseSort("order")
+	die.If(err)
+
+	for {
+		tasks := c.Filter(ws.EntryTasks(entryID)).SortBy(sorter.WithOrder(entry.Tasks))
+		inFocus := map[uint64]bool{}
+		for _, task := range ws.FocusTasks(entryID) {
+			inFocus[task.ID] = true
+		}
+
+		fmt.Printf("TODO %s (%d tasks, %d in focus):\n",
+			ws.Today().Format(workspace.DateFormat),
+			len(tasks), len(inFocus))
+		for i, task := range tasks {
+			marker := " "
+			if inFocus[task.ID] {
+				marker = "*"
+			}
+			fmt.Println(i, marker, task)
+		}
+
+		fmt.Printf("Task: ")
+		line := readline()
+		if line == "" {
+			break
+		}
+
+		idx, err := strconv.Atoi(line)
+		die.If(err)
+
+		if idx >= len(tasks) || idx < 0 {
+			continue
+		}
+
+		task := tasks[idx]
+		fmt.Printf("Move to (t)op, (b)ottom, (u)p, (d)own, or position: ")
+		pos, ok := target(entry, tasks, idx, readline())
+		if !ok {
+			continue
+		}
+
+		entry.Move(task.ID, pos)
+		err = workspace.WriteFile(ws)
+		die.If(err)
+	}
+}
@@ -18,18 +18,20 @@ func usage() {
 	fmt.Printf(`%s is a utility to change the priority of a task.
 
 Usage:
-%s [-h] [-i] workspace
+%s [-h] [-i] [-sort keys] workspace
 
 Flags:
     -h                       Print this usage message.
     -i                       Initialise a new workspace if needed.
+    -sort keys               Sort tasks by the given keys.
 
 When run, %s will display the numbered current list of unfinished
 tasks; the user should select the task to reprioritise.
 
 %s
 
-`, name, name, name, workspace.PriorityStrings)
+%s
+`, name, name, name, workspace.PriorityStrings, workspace.SortUsage)
 }
 
 var stdin = bufio.NewReader(os.Stdin)
@@ -61,9 +63,11 @@ func readPriority() workspace.Priority {
 
 func main() {
 	var shouldInit bool
+	var sortSpec string
 
 	flag.Usage = usage
 	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
+	flag.StringVar(&sortSpec, "sort", "", "Sort tasks by the given keys.")
 	flag.Parse()
 
 	if flag.NArg() == 0 {
@@ -74,16 +78,19 @@ func main() {
 	die.If(err)
 
 	entryID := ws.NewEntry()
+	sorter, err := workspace.LoadEntrySort(sortSpec)
+	die.If(err)
+	sorter = sorter.WithOrder(ws.Entries[entryID].Tasks)
 	for {
 		var c *workspace.FilterChain
 		if flag.NArg() == 1 {
-			c, err = workspace.ProcessQuery([]string{}, workspace.StatusUncompleted)
+			c, err = ws.Query([]string{}, workspace.StatusUncompleted)
 		} else {
-			c, err = workspace.ProcessQuery(flag.Args()[1:], workspace.StatusUncompleted)
+			c, err = ws.Query(flag.Args()[1:], workspace.StatusUncompleted)
 		}
 		die.If(err)
 
-		tasks := c.Filter(ws.EntryTasks(entryID)).Sort()
+		tasks := c.Filter(ws.EntryTasks(entryID)).SortBy(sorter)
 		fmt.Println("Today's TODO:")
 		for i, task := range tasks {
 			fmt.Println(i, task)
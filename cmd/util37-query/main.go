@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to manage saved queries.
+
+Usage:
+%s [-d] [-format fmt] [-h] [-i] workspace [name [query...]]
+%s -g [-d] [-format fmt] [-h] [name [query...]]
+
+Flags:
+    -d                       Delete the named query.
+    -format fmt              Select the output format for listing and
+                             showing queries: text, json or ndjson.
+                             JSON output is an object with a "queries"
+                             array; NDJSON output has one query per
+                             line. Each query has its "name", its
+                             "query" words and their "expansion".
+    -g                       Operate on the global queries, which are
+                             shared by all workspaces.
+    -h                       Print this usage message.
+    -i                       Initialise a new workspace if needed.
+
+With no name, the saved queries are listed. With only a name, the
+query and its expansion are shown. Otherwise, the query is saved
+under the name, replacing any previous definition.
+
+Saved queries are referenced in any filter as @name, and may refer to
+other saved queries. Workspace queries take precedence over global
+queries with the same name.
+
+The query should follow the filter language:
+%s
+`, name, name, name, workspace.FilterUsage)
+}
+
+// writeRecords writes the named queries as JSON or NDJSON.
+func writeRecords(format string, qs workspace.Queries, names []string, sets ...workspace.Queries) {
+	var records = make([]workspace.QueryRecord, 0, len(names))
+	for _, name := range names {
+		records = append(records, qs.Record(name, sets...))
+	}
+
+	if format == workspace.FormatNDJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for i := range records {
+			die.If(enc.Encode(records[i]))
+		}
+		return
+	}
+
+	out, err := json.MarshalIndent(struct {
+		Version int                     `json:"version"`
+		Queries []workspace.QueryRecord `json:"queries"`
+	}{workspace.ReportVersion, records}, "", "    ")
+	die.If(err)
+	fmt.Printf("%s\n", out)
+}
+
+func list(format string, qs workspace.Queries, sets ...workspace.Queries) {
+	if format != workspace.FormatText {
+		writeRecords(format, qs, qs.Names(), sets...)
+		return
+	}
+
+	for _, name := range qs.Names() {
+		fmt.Printf("@%s\t%s\n", name, qs.String(name))
+	}
+}
+
+func show(format string, qs workspace.Queries, name string, sets ...workspace.Queries) {
+	if _, ok := qs[name]; !ok {
+		die.With("No query named @%s.", name)
+	}
+
+	if format != workspace.FormatText {
+		writeRecords(format, qs, []string{name}, sets...)
+		return
+	}
+
+	fmt.Printf("@%s\t%s\n", name, qs.String(name))
+	words, err := workspace.ExpandQueries([]string{"@" + name}, sets...)
+	die.If(err)
+	fmt.Printf("\texpands to: %s\n", strings.Join(words, " "))
+}
+
+// define saves the query after checking that it expands and parses.
+func define(qs workspace.Queries, name string, query []string, sets ...workspace.Queries) {
+	if !workspace.ValidQueryName(name) {
+		die.With("Invalid query name %s.", name)
+	}
+
+	old, exists := qs[name]
+	qs[name] = workspace.SplitQuery(query)
+	words, err := workspace.ExpandQueries([]string{"@" + name}, sets...)
+	if err == nil {
+		_, err = workspace.ProcessQuery(words, workspace.StatusAny)
+	}
+
+	if err != nil {
+		if exists {
+			qs[name] = old
+		} else {
+			delete(qs, name)
+		}
+		die.If(err)
+	}
+}
+
+func main() {
+	var shouldInit, global, remove bool
+	var format = workspace.FormatText
+
+	flag.Usage = usage
+	flag.BoolVar(&remove, "d", false, "Delete the named query.")
+	flag.StringVar(&format, "format", format, "Select the output format.")
+	flag.BoolVar(&global, "g", false, "Operate on global queries.")
+	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
+	flag.Parse()
+
+	switch format {
+	case workspace.FormatText, workspace.FormatJSON, workspace.FormatNDJSON:
+	default:
+		die.With("Unsupported output format %s.", format)
+	}
+
+	args := flag.Args()
+
+	var ws *workspace.Workspace
+	var qs workspace.Queries
+	var sets []workspace.Queries
+
+	globals, err := workspace.ReadQueries()
+	die.If(err)
+
+	if global {
+		qs = globals
+		sets = []workspace.Queries{qs}
+	} else {
+		if len(args) == 0 {
+			die.With("Workspace name is required.")
+		}
+
+		ws, err = workspace.ReadFile(args[0], shouldInit)
+		die.If(err)
+		args = args[1:]
+
+		if ws.Queries == nil {
+			ws.Queries = workspace.Queries{}
+		}
+		qs = ws.Queries
+		sets = []workspace.Queries{qs, globals}
+	}
+
+	switch {
+	case len(args) == 0:
+		if remove {
+			die.With("Query name is required.")
+		}
+		list(format, qs, sets...)
+		return
+	case remove:
+		if _, ok := qs[args[0]]; !ok {
+			die.With("No query named @%s.", args[0])
+		}
+		delete(qs, args[0])
+	case len(args) == 1:
+		show(format, qs, args[0], sets...)
+		return
+	default:
+		define(qs, args[0], args[1:], sets...)
+	}
+
+	if global {
+		err = workspace.WriteQueries(qs)
+	} else {
+		err = workspace.WriteFile(ws)
+	}
+	die.If(err)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to rename, merge and delete tags.
+
+Usage:
+%s [-h] workspace rename old new
+%s [-h] workspace merge from into
+%s [-h] workspace delete tag
+
+Flags:
+    -h                       Print this usage message.
+
+Each operation applies to the tag and every tag beneath it in the
+hierarchy; renaming client/acme to client/acme-corp also renames
+client/acme/billing to client/acme-corp/billing. Both the tasks and
+the workspace's tag index are updated together.
+
+A tag can't be renamed to one that is already in use; merge them
+instead.
+`, name, name, name, name)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 3 {
+		usage()
+		os.Exit(1)
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), false)
+	die.If(err)
+
+	var n int
+	switch op := flag.Arg(1); op {
+	case "rename", "merge":
+		if flag.NArg() != 4 {
+			usage()
+			os.Exit(1)
+		}
+
+		if op == "rename" {
+			n, err = ws.RenameTag(flag.Arg(2), flag.Arg(3))
+		} else {
+			n, err = ws.MergeTag(flag.Arg(2), flag.Arg(3))
+		}
+		die.If(err)
+	case "delete":
+		if flag.NArg() != 3 {
+			usage()
+			os.Exit(1)
+		}
+
+		n = ws.DeleteTag(flag.Arg(2))
+	default:
+		die.With("Unknown operation %s.", op)
+	}
+
+	err = workspace.WriteFile(ws)
+	die.If(err)
+	fmt.Printf("Updated %d tasks.\n", n)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/kisom/goutils/die"
 	"github.com/kisom/utility37/workspace"
@@ -16,53 +17,48 @@ func usage() {
 time range.
 
 Usage:
-%s [-h] [-l] [-m] [-p priority] workspace query...
+%s [-format fmt] [-group by] [-h] [-l] [-m] [-p priority]
+    [-sort keys] [-template file] workspace query...
 
 Flags:
+    -format fmt              Select the output format: text, markdown,
+                             json, ndjson or html.
+    -group by                Group tasks in HTML output by tag, priority
+                             or none (the default).
     -h                       Print this usage message.
     -l                       Print task annotations (long format).
     -m                       Display report in markdown format.
     -p priority              Filter tasks by priority; only tasks with at
                              least the specified priority.
+    -sort keys               Sort tasks by the given keys.
+    -template file           Format the text or markdown output with the
+                             Go text/template in file.
 
 The query should follow the filter language:
 %s
-`, name, name, workspace.FilterUsage)
-}
-
-func header(timeRange string) string {
-	h := "Completed tasks finished "
-	h += timeRange
-	return h
-}
 
-func asMarkdown(tasks []*workspace.Task, long bool, timeRange string) {
-	fmt.Println("## " + header(timeRange))
+%s
 
-	if len(tasks) == 0 {
-		fmt.Println("No tasks found.")
-	} else {
-		for _, task := range tasks {
-			fmt.Printf("#### %s\n", task)
-			if long {
-				fmt.Printf("+ Completed in %s\n",
-					task.TimeTaken())
-				for _, note := range task.Notes {
-					fmt.Println(workspace.Wrap("+ "+note, "", 72))
-				}
-			}
-		}
-	}
+%s
+`, name, name, workspace.FilterUsage, workspace.SortUsage, workspace.FormatUsage)
 }
 
 func main() {
 	flag.Usage = usage
 	var long, markdown bool
+	var sortSpec string
+	var format = workspace.FormatText
+	var tmplFile string
+	var group = workspace.GroupNone
 	var priority = workspace.PriorityNormal.String()
 
 	flag.BoolVar(&long, "l", false, "Print annotations on tasks.")
 	flag.BoolVar(&markdown, "m", false, "Print review as markdown.")
 	flag.StringVar(&priority, "p", priority, "Filter tasks by priority")
+	flag.StringVar(&sortSpec, "sort", "", "Sort tasks by the given keys.")
+	flag.StringVar(&format, "format", format, "Select the output format.")
+	flag.StringVar(&tmplFile, "template", "", "Format the output with a template.")
+	flag.StringVar(&group, "group", group, "Group tasks in HTML output.")
 	flag.Parse()
 	if flag.NArg() == 0 {
 		usage()
@@ -74,40 +70,49 @@ func main() {
 	}
 	name := flag.Arg(0)
 
-	var err error
+	if markdown {
+		format = workspace.FormatMarkdown
+	}
+	die.If(workspace.CheckFormat(format))
+
+	ws, err := workspace.ReadFile(name, false)
+	die.If(err)
+
 	var c *workspace.FilterChain
 	if flag.NArg() == 1 {
-		c, err = workspace.ProcessQuery([]string{"last:2w"}, workspace.StatusCompleted)
+		c, err = ws.Query([]string{"last:2w"}, workspace.StatusCompleted)
 	} else {
-		c, err = workspace.ProcessQuery(flag.Args()[1:], workspace.StatusCompleted)
+		c, err = ws.Query(flag.Args()[1:], workspace.StatusCompleted)
 	}
 	die.If(err)
 
-	ws, err := workspace.ReadFile(name, false)
-	die.If(err)
-
 	tasks := c.Filter(ws.Tasks)
-	sorted := tasks.Sort()
-
-	if markdown {
-		asMarkdown(sorted, long, c.TimeRange())
-	} else {
-		fmt.Println(header(c.TimeRange()))
-		if len(tasks) > 0 {
-			for i, task := range sorted {
-				fmt.Println(sorted[i])
-				if long {
-					fmt.Printf("\tCompletion time: %s\n", task.TimeTaken())
-					if len(task.Tags) > 0 {
-						fmt.Println("\tTags:", task.TagString())
-					}
-					for _, note := range sorted[i].Notes {
-						fmt.Println(workspace.Wrap("+ "+note, "\t", 72))
-					}
-				}
-			}
-		} else {
-			fmt.Println("No tasks found.")
-		}
+	sorter, err := workspace.LoadSort(sortSpec)
+	die.If(err)
+	sorted := tasks.SortBy(sorter)
+
+	switch format {
+	case workspace.FormatJSON, workspace.FormatNDJSON:
+		r := workspace.NewReport("review", ws, c, sorted)
+		die.If(r.Write(os.Stdout, format))
+	case workspace.FormatHTML:
+		title := "Completed tasks finished " + c.TimeRange()
+		r, err := workspace.NewHTMLReport(title, ws, c, sorted, group)
+		die.If(err)
+		die.If(r.WriteHTML(os.Stdout))
+	default:
+		tmpl, err := workspace.LoadTemplate("review", format, ws.Name, tmplFile)
+		die.If(err)
+
+		err = tmpl.Execute(os.Stdout, &workspace.TemplateData{
+			Workspace: ws.Name,
+			Report:    "review",
+			Date:      time.Now(),
+			Range:     c.TimeRange(),
+			Long:      long,
+			Count:     len(sorted),
+			Tasks:     sorted,
+		})
+		die.If(err)
 	}
 }
@@ -0,0 +1,567 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kisom/utility37/workspace"
+)
+
+// An apiError is an error with the HTTP status to report it with.
+type apiError struct {
+	code int
+	msg  string
+}
+
+func (e *apiError) Error() string {
+	return e.msg
+}
+
+func errorf(code int, format string, args ...interface{}) error {
+	return &apiError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// server serves the API. Workspaces are read from disk for every
+// request, so that changes made with the other tools are seen, and
+// changes to a workspace are made one at a time.
+type server struct {
+	token string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newServer(token string) *server {
+	return &server{token: token, locks: map[string]*sync.Mutex{}}
+}
+
+// lock locks the named workspace, returning the function to unlock
+// it.
+func (s *server) lock(name string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[name]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[name] = l
+	}
+	s.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+func (s *server) read(name string) (*workspace.Workspace, error) {
+	if !workspace.ValidName(name) {
+		return nil, errorf(http.StatusBadRequest, "invalid workspace name %q", name)
+	}
+
+	ws, err := workspace.ReadFile(name, false)
+	if os.IsNotExist(err) {
+		return nil, errorf(http.StatusNotFound, "no workspace named %s", name)
+	}
+	return ws, err
+}
+
+type handler func(*workspace.Workspace) (interface{}, error)
+
+// view calls fn with the named workspace, without saving it.
+func (s *server) view(name string, fn handler) (interface{}, error) {
+	defer s.lock(name)()
+	ws, err := s.read(name)
+	if err != nil {
+		return nil, err
+	}
+	return fn(ws)
+}
+
+// update calls fn with the named workspace and saves it. If another
+// program writes the workspace in the meantime, fn is called again
+// with its new contents rather than overwriting them.
+func (s *server) update(name string, fn handler) (interface{}, error) {
+	defer s.lock(name)()
+	for attempt := 0; attempt < 3; attempt++ {
+		ws, err := s.read(name)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := fn(ws)
+		if err != nil {
+			return nil, err
+		}
+
+		err = workspace.WriteFile(ws)
+		if err == workspace.ErrChanged {
+			continue
+		}
+		return result, err
+	}
+
+	return nil, errorf(http.StatusConflict, "workspace %s is being changed by another program", name)
+}
+
+func (s *server) authorised(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	out, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		code = http.StatusInternalServerError
+		out, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write(append(out, '\n'))
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	if e, ok := err.(*apiError); ok {
+		code = e.code
+	} else {
+		log.Print(err)
+	}
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorised(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="util37"`)
+		writeError(w, errorf(http.StatusUnauthorized, "a valid token is required"))
+		return
+	}
+
+	if err := checkContentType(r); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	code, result, err := s.route(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, code, result)
+}
+
+// checkContentType requires requests that make changes to be sent as
+// JSON, which a web page on another site can't do without the browser
+// asking the server first.
+func checkContentType(r *http.Request) error {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
+	default:
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errorf(http.StatusUnsupportedMediaType, "%s requests must have Content-Type application/json", r.Method)
+	}
+	return nil
+}
+
+func methodNotAllowed(r *http.Request) error {
+	return errorf(http.StatusMethodNotAllowed, "%s isn't allowed for %s", r.Method, r.URL.Path)
+}
+
+func notFound(r *http.Request) error {
+	return errorf(http.StatusNotFound, "%s wasn't found", r.URL.Path)
+}
+
+// route dispatches a request, returning the status and the value to
+// write as the response.
+func (s *server) route(r *http.Request) (int, interface{}, error) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+	parts := strings.Split(path, "/")
+	if parts[0] != "workspaces" {
+		return 0, nil, notFound(r)
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			return 0, nil, methodNotAllowed(r)
+		}
+
+		names, err := workspace.ListWorkspaces()
+		if names == nil {
+			names = []string{}
+		}
+		return http.StatusOK, map[string][]string{"workspaces": names}, err
+	}
+
+	if len(parts) == 2 {
+		return 0, nil, notFound(r)
+	}
+
+	name := parts[1]
+	switch parts[2] {
+	case "tasks":
+		return s.routeTasks(r, name, parts[3:])
+	case "entries":
+		return s.routeEntries(r, name, parts[3:])
+	case "review":
+		if len(parts) != 3 {
+			return 0, nil, notFound(r)
+		} else if r.Method != http.MethodGet {
+			return 0, nil, methodNotAllowed(r)
+		}
+		result, err := s.view(name, reviewHandler(r))
+		return http.StatusOK, result, err
+	}
+	return 0, nil, notFound(r)
+}
+
+// actionMethods gives the method each of a task's actions is made
+// with.
+var actionMethods = map[string]string{
+	"complete": http.MethodPost,
+	"cancel":   http.MethodPost,
+	"tags":     http.MethodPost,
+	"notes":    http.MethodPost,
+	"priority": http.MethodPut,
+}
+
+func (s *server) routeTasks(r *http.Request, name string, parts []string) (int, interface{}, error) {
+	if len(parts) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			result, err := s.view(name, queryHandler(r))
+			return http.StatusOK, result, err
+		case http.MethodPost:
+			var req newTaskRequest
+			if err := decode(r, &req); err != nil {
+				return 0, nil, err
+			}
+			result, err := s.update(name, req.handler)
+			return http.StatusCreated, result, err
+		}
+		return 0, nil, methodNotAllowed(r)
+	}
+
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, nil, errorf(http.StatusBadRequest, "invalid task ID %s", parts[0])
+	}
+
+	var fn taskHandler
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && len(parts) == 1 && r.Method == http.MethodGet:
+		result, err := s.view(name, withTask(id, func(ws *workspace.Workspace, task *workspace.Task) error {
+			return nil
+		}))
+		return http.StatusOK, result, err
+	case action == "":
+		return 0, nil, methodNotAllowed(r)
+	case len(parts) > 2 && action == "tags" && r.Method == http.MethodDelete:
+		fn = untagHandler(strings.Join(parts[2:], "/"))
+	case len(parts) > 2 || actionMethods[action] == "":
+		return 0, nil, notFound(r)
+	case r.Method != actionMethods[action]:
+		return 0, nil, methodNotAllowed(r)
+	case action == "complete":
+		fn = completeHandler
+	case action == "cancel":
+		fn = cancelHandler
+	case action == "tags":
+		var req tagsRequest
+		if err := decode(r, &req); err != nil {
+			return 0, nil, err
+		}
+		fn = req.handler
+	case action == "notes":
+		var req noteRequest
+		if err := decode(r, &req); err != nil {
+			return 0, nil, err
+		}
+		fn = req.handler
+	case action == "priority":
+		var req priorityRequest
+		if err := decode(r, &req); err != nil {
+			return 0, nil, err
+		}
+		fn = req.handler
+	}
+
+	result, err := s.update(name, withTask(id, fn))
+	return http.StatusOK, result, err
+}
+
+func (s *server) routeEntries(r *http.Request, name string, parts []string) (int, interface{}, error) {
+	if r.Method != http.MethodGet {
+		return 0, nil, methodNotAllowed(r)
+	}
+
+	switch {
+	case len(parts) == 0:
+		result, err := s.view(name, listEntries)
+		return http.StatusOK, result, err
+	case len(parts) > 1:
+		return 0, nil, notFound(r)
+	case parts[0] == "today":
+		// Today's entry is shown as the other tools would create
+		// it, but it is only saved when a change is made.
+		result, err := s.view(name, func(ws *workspace.Workspace) (interface{}, error) {
+			return ws.EntryRecord(ws.NewEntry(), true), nil
+		})
+		return http.StatusOK, result, err
+	}
+
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, nil, errorf(http.StatusBadRequest, "invalid entry ID %s", parts[0])
+	}
+
+	result, err := s.view(name, func(ws *workspace.Workspace) (interface{}, error) {
+		if _, ok := ws.Entries[id]; !ok {
+			return nil, errorf(http.StatusNotFound, "no entry %d in %s", id, name)
+		}
+		return ws.EntryRecord(id, true), nil
+	})
+	return http.StatusOK, result, err
+}
+
+// decode reads a JSON request body into v.
+func decode(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+	if err := dec.Decode(v); err != nil {
+		return errorf(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+var statuses = map[string]workspace.CompletionStatus{
+	"open": workspace.StatusUncompleted,
+	"done": workspace.StatusCompleted,
+	"any":  workspace.StatusAny,
+}
+
+// report returns a handler for a report of the tasks matching the
+// request's query, given in the q parameter, or def if there is none.
+func report(r *http.Request, kind string, def []string, status workspace.CompletionStatus) handler {
+	params := r.URL.Query()
+	return func(ws *workspace.Workspace) (interface{}, error) {
+		query := strings.Fields(params.Get("q"))
+		if len(query) == 0 {
+			query = def
+		}
+
+		c, err := ws.Query(query, status)
+		if err != nil {
+			return nil, errorf(http.StatusBadRequest, "%v", err)
+		}
+
+		sorter, err := workspace.LoadSort(params.Get("sort"))
+		if err != nil {
+			return nil, errorf(http.StatusBadRequest, "%v", err)
+		}
+
+		tasks := c.Filter(ws.Tasks).SortBy(sorter)
+		return workspace.NewReport(kind, ws, c, tasks), nil
+	}
+}
+
+func queryHandler(r *http.Request) handler {
+	name := r.URL.Query().Get("status")
+	if name == "" {
+		name = "open"
+	}
+
+	status, ok := statuses[name]
+	if !ok {
+		return func(*workspace.Workspace) (interface{}, error) {
+			return nil, errorf(http.StatusBadRequest, "invalid status %q", name)
+		}
+	}
+	return report(r, "query", nil, status)
+}
+
+func reviewHandler(r *http.Request) handler {
+	return report(r, "review", []string{"last:2w"}, workspace.StatusCompleted)
+}
+
+func listEntries(ws *workspace.Workspace) (interface{}, error) {
+	var ids []uint64
+	for id := range ws.Entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	entries := make([]workspace.EntryRecord, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, ws.EntryRecord(id, false))
+	}
+	return map[string]interface{}{"entries": entries}, nil
+}
+
+type newTaskRequest struct {
+	Title    string   `json:"title"`
+	Priority string   `json:"priority"`
+	Tags     []string `json:"tags"`
+	Notes    []string `json:"notes"`
+	Due      string   `json:"due"`
+	Wait     string   `json:"wait"`
+}
+
+// handler adds the task to the workspace and, unless it is waiting,
+// today's entry, as util37-todo does.
+func (req *newTaskRequest) handler(ws *workspace.Workspace) (interface{}, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, errorf(http.StatusBadRequest, "a title is required")
+	}
+
+	pri := workspace.PriorityNormal
+	if req.Priority != "" {
+		pri = workspace.ParsePriority(req.Priority)
+		if pri == workspace.PriorityUnknown {
+			return nil, errorf(http.StatusBadRequest, "invalid priority %q", req.Priority)
+		}
+	}
+
+	entryID := ws.NewEntry()
+	id := workspace.NewTaskID()
+	for ws.Tasks[id] != nil {
+		id++
+	}
+
+	task := workspace.NewTask(id, title)
+	task.Priority = pri
+	task.Notes = req.Notes
+
+	var err error
+	if req.Due != "" {
+		if task.Due, err = ws.ParseDay(req.Due); err != nil {
+			return nil, errorf(http.StatusBadRequest, "%v", err)
+		}
+	}
+
+	var wait time.Time
+	if req.Wait != "" {
+		if wait, err = ws.ParseWait(req.Wait); err != nil {
+			return nil, errorf(http.StatusBadRequest, "%v", err)
+		}
+	}
+
+	ws.Tasks[id] = task
+	for _, tag := range req.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			ws.Tag(id, tag)
+		}
+	}
+
+	entry := ws.Entries[entryID]
+	entry.Tasks = append(entry.Tasks, id)
+	if !wait.IsZero() {
+		ws.Snooze(id, wait)
+	}
+	return task.Record(), nil
+}
+
+type taskHandler func(*workspace.Workspace, *workspace.Task) error
+
+// withTask returns a handler that calls fn with the task, and returns
+// the task's record.
+func withTask(id uint64, fn taskHandler) handler {
+	return func(ws *workspace.Workspace) (interface{}, error) {
+		task, ok := ws.Tasks[id]
+		if !ok {
+			return nil, errorf(http.StatusNotFound, "no task %d in %s", id, ws.Name)
+		}
+
+		if err := fn(ws, task); err != nil {
+			return nil, err
+		}
+		return task.Record(), nil
+	}
+}
+
+func completeHandler(ws *workspace.Workspace, task *workspace.Task) error {
+	if !task.Done {
+		task.MarkDone()
+	}
+	return nil
+}
+
+func cancelHandler(ws *workspace.Workspace, task *workspace.Task) error {
+	if !task.Done {
+		task.MarkCancelled()
+	}
+	return nil
+}
+
+type tagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+func (req *tagsRequest) handler(ws *workspace.Workspace, task *workspace.Task) error {
+	for _, tag := range req.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			ws.Tag(task.ID, tag)
+		}
+	}
+	return nil
+}
+
+func untagHandler(tag string) taskHandler {
+	return func(ws *workspace.Workspace, task *workspace.Task) error {
+		if !ws.Untag(task.ID, tag) {
+			return errorf(http.StatusNotFound, "task %d isn't tagged %s", task.ID, tag)
+		}
+		return nil
+	}
+}
+
+type noteRequest struct {
+	Note string `json:"note"`
+}
+
+func (req *noteRequest) handler(ws *workspace.Workspace, task *workspace.Task) error {
+	note := strings.TrimSpace(req.Note)
+	if note == "" {
+		return errorf(http.StatusBadRequest, "a note is required")
+	}
+	task.Notes = append(task.Notes, note)
+	return nil
+}
+
+type priorityRequest struct {
+	Priority string `json:"priority"`
+}
+
+func (req *priorityRequest) handler(ws *workspace.Workspace, task *workspace.Task) error {
+	pri := workspace.ParsePriority(req.Priority)
+	if pri == workspace.PriorityUnknown {
+		return errorf(http.StatusBadRequest, "invalid priority %q", req.Priority)
+	}
+	task.Priority = pri
+	return nil
+}
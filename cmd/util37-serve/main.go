@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kisom/goutils/die"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to serve workspaces over a local HTTP API,
+with a web interface.
+
+Usage:
+%s [-addr address] [-h] [-no-token] [-token token]
+
+Flags:
+    -addr address            Listen on the given address (default
+                             localhost:3737).
+    -h                       Print this usage message.
+    -no-token                Don't require a token, even if one is
+                             given with -token or UTIL37_TOKEN. Any
+                             program that can reach the address can
+                             then use the API.
+    -token token             Require the token in an "Authorization:
+                             Bearer" header on every request; the
+                             default is the UTIL37_TOKEN environment
+                             variable. Without either, a token is
+                             generated and printed when %s starts.
+
+The web interface, at http://localhost:3737/ by default, shows today's
+tasks and lets them be added, completed, tagged, prioritised and
+annotated, and shows review reports. It is built in, and works without
+a network connection; it asks for the token the first time it is used,
+unless the server was started with -no-token.
+
+Requests are only accepted if their Host header names localhost, a
+loopback address or the host given with -addr, so that web pages can't
+reach the API by pointing their own domains at it. Requests that send
+changes (POST, PUT and DELETE) must have a Content-Type of
+application/json, even without a body.
+
+%s serves a JSON API for the workspaces in ~/.config/util37. Each
+request reads the workspace from disk, so changes made with the other
+tools are seen straight away, and changes made through the API are
+applied one at a time; if another program writes the workspace while
+a change is being made, the change is made again on the new contents.
+
+Endpoints:
+
+    GET    /api/workspaces
+           List the workspaces.
+    GET    /api/workspaces/NAME/tasks?q=QUERY&status=STATUS&sort=KEYS
+           A report, as util37-today -format json writes, of the
+           tasks matching the query in the filter language. The
+           status is open (the default), done or any.
+    POST   /api/workspaces/NAME/tasks
+           Add a task, given as {"title": ..., "priority": ...,
+           "tags": [...], "notes": [...], "due": DAY, "wait": DAY}.
+           Only the title is required.
+    GET    /api/workspaces/NAME/tasks/ID
+           A task.
+    POST   /api/workspaces/NAME/tasks/ID/complete
+    POST   /api/workspaces/NAME/tasks/ID/cancel
+           Complete or cancel a task.
+    POST   /api/workspaces/NAME/tasks/ID/tags
+           Tag a task, given {"tags": [...]}.
+    DELETE /api/workspaces/NAME/tasks/ID/tags/TAG
+           Remove a tag from a task.
+    POST   /api/workspaces/NAME/tasks/ID/notes
+           Annotate a task, given {"note": ...}.
+    PUT    /api/workspaces/NAME/tasks/ID/priority
+           Set a task's priority, given {"priority": ...} as a name,
+           such as "high", or a specifier, such as "H".
+    GET    /api/workspaces/NAME/entries
+           List the entries, without their tasks.
+    GET    /api/workspaces/NAME/entries/today
+    GET    /api/workspaces/NAME/entries/ID
+           An entry and its tasks; today's entry is created if needed.
+    GET    /api/workspaces/NAME/review?q=QUERY&sort=KEYS
+           A report of the tasks completed in the range given by the
+           query (default last:2w), as util37-review writes.
+
+Changes return the task's JSON record. Errors are returned as
+{"error": ...} with an appropriate status.
+`, name, name, name, name)
+}
+
+// localHost returns true if host names the local machine.
+func localHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkHost rejects requests whose Host header doesn't name the local
+// machine or the host the server listens on, so that a page from a
+// domain that resolves to the local machine can't use the API.
+func checkHost(addr string, next http.Handler) http.Handler {
+	listen, _, _ := net.SplitHostPort(addr)
+	if ip := net.ParseIP(listen); ip != nil && ip.IsUnspecified() {
+		listen = ""
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+
+		if !localHost(host) && (listen == "" || !strings.EqualFold(host, listen)) {
+			writeError(w, errorf(http.StatusForbidden, "requests for host %q aren't accepted", r.Host))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newToken returns a random token.
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func main() {
+	var addr = "localhost:3737"
+	var token = os.Getenv("UTIL37_TOKEN")
+	var noToken bool
+
+	flag.Usage = usage
+	flag.StringVar(&addr, "addr", addr, "Listen on the given address.")
+	flag.BoolVar(&noToken, "no-token", false, "Don't require a token.")
+	flag.StringVar(&token, "token", token, "Require the given token.")
+	flag.Parse()
+
+	if noToken {
+		token = ""
+		if host, _, err := net.SplitHostPort(addr); err != nil || !localHost(host) {
+			log.Printf("warning: serving %s without a token", addr)
+		}
+	} else if token == "" {
+		var err error
+		token, err = newToken()
+		die.If(err)
+		fmt.Fprintf(os.Stderr, "Token: %s\n", token)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", newServer(token))
+	mux.Handle("/", uiHandler())
+
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      checkHost(addr, mux),
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	log.Printf("serving on http://%s/", addr)
+	die.If(srv.ListenAndServe())
+}
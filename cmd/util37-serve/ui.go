@@ -0,0 +1,20 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/kisom/goutils/die"
+)
+
+// The web interface is a static page that uses the API.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+func uiHandler() http.Handler {
+	files, err := fs.Sub(uiFiles, "ui")
+	die.If(err)
+	return http.FileServer(http.FS(files))
+}
@@ -0,0 +1,302 @@
+// The util37 web interface. It only talks to the API served alongside
+// it, so it works without a network connection.
+"use strict";
+
+const state = {
+  workspace: localStorage.getItem("util37.workspace") || "",
+  token: localStorage.getItem("util37.token") || "",
+  view: "today",
+};
+
+const priorities = ["low", "normal", "high", "urgent"];
+
+function $(id) {
+  return document.getElementById(id);
+}
+
+function showError(err) {
+  const p = $("error");
+  p.textContent = err ? String(err.message || err) : "";
+  p.hidden = !err;
+}
+
+// api makes a request to the API, asking for the token if the server
+// doesn't accept the one stored. The server requires changes to be
+// sent as JSON, even if they have no body.
+async function api(method, path, body) {
+  const opts = { method: method, headers: {} };
+  if (state.token) {
+    opts.headers["Authorization"] = "Bearer " + state.token;
+  }
+
+  if (method !== "GET") {
+    opts.headers["Content-Type"] = "application/json";
+  }
+
+  if (body !== undefined) {
+    opts.body = JSON.stringify(body);
+  }
+
+  const resp = await fetch("/api/" + path, opts);
+  const data = await resp.json();
+  if (resp.status === 401) {
+    const token = prompt("util37-serve requires a token:");
+    if (token) {
+      state.token = token;
+      localStorage.setItem("util37.token", token);
+      return api(method, path, body);
+    }
+  }
+
+  if (!resp.ok) {
+    throw new Error(data.error || resp.statusText);
+  }
+  return data;
+}
+
+function wsPath(rest) {
+  return "workspaces/" + encodeURIComponent(state.workspace) + "/" + rest;
+}
+
+function taskPath(task, action) {
+  return wsPath("tasks/" + task.id + "/" + action);
+}
+
+// act runs a change against the API and redraws the current view.
+async function act(method, path, body) {
+  try {
+    await api(method, path, body);
+    await refresh();
+  } catch (err) {
+    showError(err);
+  }
+}
+
+function el(tag, className, text) {
+  const e = document.createElement(tag);
+  if (className) {
+    e.className = className;
+  }
+  if (text !== undefined) {
+    e.textContent = text;
+  }
+  return e;
+}
+
+function button(label, title, onclick) {
+  const b = el("button", "", label);
+  b.type = "button";
+  b.title = title;
+  b.addEventListener("click", onclick);
+  return b;
+}
+
+function day(stamp) {
+  return stamp ? stamp.slice(0, 10) : "";
+}
+
+function renderTask(task, focus, editable) {
+  const li = el("li", task.status === "open" ? "" : "done");
+  if (focus) {
+    li.classList.add("focus");
+  }
+
+  const line = el("div", "task-line");
+  const check = el("input");
+  check.type = "checkbox";
+  check.checked = task.status !== "open";
+  check.disabled = !editable || task.status !== "open";
+  check.title = "Complete";
+  check.addEventListener("change", () => act("POST", taskPath(task, "complete")));
+  line.appendChild(check);
+
+  line.appendChild(el("span", "title", task.title));
+
+  for (const tag of task.tags) {
+    const span = el("span", "tag", "#" + tag);
+    if (editable) {
+      span.appendChild(button("×", "Remove tag", () =>
+        act("DELETE", taskPath(task, "tags/" + encodeURIComponent(tag)))));
+    }
+    line.appendChild(span);
+  }
+
+  if (editable) {
+    const pri = el("select", "priority " + task.priority);
+    pri.title = "Priority";
+    for (const name of priorities) {
+      const opt = el("option", "", name);
+      opt.value = name;
+      opt.selected = name === task.priority;
+      pri.appendChild(opt);
+    }
+    pri.addEventListener("change", () =>
+      act("PUT", taskPath(task, "priority"), { priority: pri.value }));
+    line.appendChild(pri);
+
+    const actions = el("span", "actions");
+    actions.appendChild(button("tag", "Add tags", () => {
+      const tags = prompt("Tags, separated by commas:");
+      if (tags) {
+        act("POST", taskPath(task, "tags"), { tags: tags.split(",") });
+      }
+    }));
+    actions.appendChild(button("note", "Annotate", () => {
+      const note = prompt("Note:");
+      if (note) {
+        act("POST", taskPath(task, "notes"), { note: note });
+      }
+    }));
+    if (task.status === "open") {
+      actions.appendChild(button("cancel", "Cancel the task", () =>
+        act("POST", taskPath(task, "cancel"))));
+    }
+    line.appendChild(actions);
+  } else {
+    line.appendChild(el("span", "priority " + task.priority, task.priority));
+  }
+  li.appendChild(line);
+
+  const meta = ["created " + day(task.created)];
+  if (task.finished) {
+    meta.push((task.status === "cancelled" ? "cancelled " : "completed ") + day(task.finished));
+  }
+  if (task.due) {
+    meta.push("due " + day(task.due));
+  }
+  if (task.wait) {
+    meta.push("waiting until " + day(task.wait));
+  }
+  if (task.carried) {
+    meta.push("carried " + task.carried);
+  }
+  li.appendChild(el("div", "meta", meta.join(", ")));
+
+  if (task.notes.length > 0) {
+    const notes = el("ul", "notes");
+    for (const note of task.notes) {
+      notes.appendChild(el("li", "", note));
+    }
+    li.appendChild(notes);
+  }
+  return li;
+}
+
+async function showToday() {
+  const entry = await api("GET", wsPath("entries/today"));
+  const open = entry.tasks.filter((t) => t.status === "open").length;
+  $("today-title").textContent = "Today, " + day(entry.date) + " (" +
+    open + " of " + entry.tasks.length + " open)";
+
+  const list = $("today-tasks");
+  list.replaceChildren();
+  let focus = entry.focus;
+  for (const task of entry.tasks) {
+    const inFocus = focus > 0 && task.status === "open";
+    if (inFocus) {
+      focus--;
+    }
+    list.appendChild(renderTask(task, inFocus, true));
+  }
+}
+
+async function showReview() {
+  const q = $("review-query").value.trim();
+  const report = await api("GET", wsPath("review?q=" + encodeURIComponent(q)));
+  $("review-title").textContent = report.count + " tasks completed" +
+    (report.range ? " " + report.range : "");
+
+  const list = $("review-tasks");
+  list.replaceChildren();
+  for (const task of report.tasks) {
+    list.appendChild(renderTask(task, false, false));
+  }
+}
+
+async function refresh() {
+  showError(null);
+  if (!state.workspace) {
+    return;
+  }
+
+  try {
+    if (state.view === "today") {
+      await showToday();
+    } else {
+      await showReview();
+    }
+  } catch (err) {
+    showError(err);
+  }
+}
+
+function selectView(view) {
+  state.view = view;
+  for (const b of document.querySelectorAll("nav button")) {
+    b.classList.toggle("active", b.dataset.view === view);
+  }
+  $("today").hidden = view !== "today";
+  $("review").hidden = view !== "review";
+  refresh();
+}
+
+async function init() {
+  let names;
+  try {
+    names = (await api("GET", "workspaces")).workspaces;
+  } catch (err) {
+    showError(err);
+    return;
+  }
+
+  if (names.length === 0) {
+    showError("There are no workspaces yet; create one with util37-todo -i.");
+    return;
+  }
+
+  if (!names.includes(state.workspace)) {
+    state.workspace = names[0];
+  }
+
+  const sel = $("workspace");
+  for (const name of names) {
+    const opt = el("option", "", name);
+    opt.value = name;
+    opt.selected = name === state.workspace;
+    sel.appendChild(opt);
+  }
+
+  sel.addEventListener("change", () => {
+    state.workspace = sel.value;
+    localStorage.setItem("util37.workspace", sel.value);
+    refresh();
+  });
+
+  for (const b of document.querySelectorAll("nav button")) {
+    b.addEventListener("click", () => selectView(b.dataset.view));
+  }
+
+  $("add").addEventListener("submit", (ev) => {
+    ev.preventDefault();
+    const title = $("add-title").value.trim();
+    if (!title) {
+      return;
+    }
+
+    act("POST", wsPath("tasks"), {
+      title: title,
+      priority: $("add-priority").value,
+      tags: $("add-tags").value.split(","),
+    });
+    $("add-title").value = "";
+  });
+
+  $("review-form").addEventListener("submit", (ev) => {
+    ev.preventDefault();
+    refresh();
+  });
+
+  refresh();
+}
+
+init();
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to hide tasks from the day's list until a
+later day.
+
+Usage:
+%s [-h] [-l] [-sort keys] workspace [query...]
+
+Flags:
+    -h                       Print this usage message.
+    -l                       Select from the tasks that are already
+                             waiting, to change when they start or to
+                             wake them now.
+    -sort keys               Sort tasks by the given keys.
+
+When run, %s will display the numbered list of today's unfinished
+tasks; the user should select the task to snooze, then enter the day it
+should return to the list. The day may be given as YYYY-MM-DD,
+"tomorrow", the name of a weekday, or a number of days, weeks or months
+from now, such as 3d, 2w or 1m. Entering "today" wakes a waiting task,
+putting it back on today's list. An empty line exits.
+
+The query should follow the filter language:
+%s
+
+%s
+`, name, name, name, workspace.FilterUsage, workspace.SortUsage)
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func readline() string {
+	line, err := stdin.ReadString('\n')
+	die.If(err)
+
+	return strings.TrimSpace(line)
+}
+
+func main() {
+	var waiting bool
+	var sortSpec string
+
+	flag.Usage = usage
+	flag.BoolVar(&waiting, "l", false, "Select from the waiting tasks.")
+	flag.StringVar(&sortSpec, "sort", "", "Sort tasks by the given keys.")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		die.With("Workspace name is required.")
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), false)
+	die.If(err)
+
+	words := flag.Args()[1:]
+	if waiting {
+		words = append([]string{"waiting:"}, words...)
+	}
+
+	c, err := ws.Query(words, workspace.StatusUncompleted)
+	die.If(err)
+
+	entryID := ws.NewEntry()
+	sorter, err := workspace.LoadEntrySort(sortSpec)
+	die.If(err)
+	sorter = sorter.WithOrder(ws.Entries[entryID].Tasks)
+
+	for {
+		var tasks []*workspace.Task
+		if waiting {
+			tasks = c.Filter(ws.Tasks).SortBy(sorter)
+			fmt.Println("Waiting tasks:")
+		} else {
+			tasks = c.Filter(ws.EntryTasks(entryID)).SortBy(sorter)
+			fmt.Println("Today's TODO:")
+		}
+
+		for i, task := range tasks {
+			fmt.Println(i, task)
+		}
+
+		fmt.Printf("Task: ")
+		line := readline()
+		if line == "" {
+			break
+		}
+
+		idx, err := strconv.Atoi(line)
+		die.If(err)
+
+		if idx >= len(tasks) || idx < 0 {
+			continue
+		}
+
+		fmt.Printf("Until: ")
+		until, err := ws.ParseWait(readline())
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		ws.Snooze(tasks[idx].ID, until)
+		err = workspace.WriteFile(ws)
+		die.If(err)
+	}
+}
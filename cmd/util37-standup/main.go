@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to prepare a daily standup report.
+
+Usage:
+%s [-b tag] [-format fmt] [-h] [-m] [-template file] workspace...
+
+Flags:
+    -b tag                   Treat tasks with this tag, or a tag beneath
+                             it, as blocked (default "%s").
+    -format fmt              Select the output format: text, markdown
+                             or json.
+    -h                       Print this usage message.
+    -m                       Display the report in markdown format.
+    -template file           Format the text or markdown output with the
+                             Go text/template in file.
+
+For each workspace, the report lists the tasks completed since the
+previous day's list was started, today's unfinished tasks, and the
+blockers: tasks tagged as blocked, or that mention being blocked in
+their title or notes. With several workspaces, a report is given for
+each in turn.
+`, name, name, workspace.BlockedTag)
+}
+
+func main() {
+	var markdown bool
+	var format = workspace.FormatText
+	var tmplFile string
+	var blockedTag = workspace.BlockedTag
+
+	flag.Usage = usage
+	flag.StringVar(&blockedTag, "b", blockedTag, "Tag marking blocked tasks.")
+	flag.StringVar(&format, "format", format, "Select the output format.")
+	flag.BoolVar(&markdown, "m", false, "Print the report as markdown.")
+	flag.StringVar(&tmplFile, "template", "", "Format the output with a template.")
+	flag.Parse()
+
+	if markdown {
+		format = workspace.FormatMarkdown
+	}
+
+	switch format {
+	case workspace.FormatText, workspace.FormatMarkdown, workspace.FormatJSON:
+	default:
+		die.With("Unsupported format %s.", format)
+	}
+
+	if flag.NArg() == 0 {
+		die.With("At least one workspace name is required.")
+	}
+
+	var standups []*workspace.Standup
+	for _, name := range flag.Args() {
+		ws, err := workspace.ReadFile(name, false)
+		die.If(err)
+		standups = append(standups, ws.Standup(blockedTag))
+	}
+
+	if format == workspace.FormatJSON {
+		die.If(workspace.WriteStandupJSON(os.Stdout, standups))
+		return
+	}
+
+	for i, s := range standups {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		tmpl, err := workspace.LoadTemplate("standup", format, s.Workspace, tmplFile)
+		die.If(err)
+		die.If(tmpl.Execute(os.Stdout, s))
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to report productivity statistics.
+
+Usage:
+%s [-format fmt] [-h] [-n periods] [-period period] workspace [query...]
+
+Flags:
+    -format fmt              Select the output format: text or json.
+    -h                       Print this usage message.
+    -n periods               The number of periods to show throughput
+                             for (default 8).
+    -period period           Count throughput per "day" or "week"
+                             (default "week").
+
+%s reports the number of tasks created and completed in each
+period, the median and 90th percentile time taken to complete a task,
+the size and age of the open backlog, and a breakdown by tag and
+priority. Both open and completed tasks are included; the query may be
+used to narrow them down. If the query contains from:, to: or last:,
+the throughput covers that range instead of the last few periods.
+
+In JSON output, durations are given in nanoseconds.
+
+The query should follow the filter language:
+%s
+`, name, name, name, workspace.FilterUsage)
+}
+
+func printText(s *workspace.Stats) {
+	fmt.Printf("Throughput per %s:\n", s.Period)
+	fmt.Printf("\t%-10s  %7s  %9s\n", "Starting", "Created", "Completed")
+	for _, b := range s.Buckets {
+		fmt.Printf("\t%-10s  %7d  %9d\n", b.Start.Format(workspace.DateFormat),
+			b.Created, b.Completed)
+	}
+
+	fmt.Printf("\nTasks: %d (%d open, %d completed)\n", s.Total, s.Open, s.Done)
+	if s.Done > 0 {
+		fmt.Printf("Time to complete: median %s, 90th percentile %s\n",
+			workspace.FormatDuration(s.Median),
+			workspace.FormatDuration(s.P90))
+	}
+
+	fmt.Printf("\nOpen backlog by age:\n")
+	for _, age := range s.Ages {
+		fmt.Printf("\t%-16s  %5d\n", age.Label, age.Count)
+	}
+
+	breakdowns := func(title string, bs []workspace.Breakdown) {
+		if len(bs) == 0 {
+			return
+		}
+
+		fmt.Printf("\n%s:\n", title)
+		fmt.Printf("\t%-24s  %5s  %5s  %8s\n", "", "Open", "Done", "Median")
+		for _, b := range bs {
+			median := "-"
+			if b.Done > 0 {
+				median = workspace.FormatDuration(b.Median)
+			}
+			fmt.Printf("\t%-24s  %5d  %5d  %8s\n", b.Name, b.Open, b.Done, median)
+		}
+	}
+	breakdowns("By priority", s.ByPriority)
+	breakdowns("By tag", s.ByTag)
+}
+
+func main() {
+	var format = workspace.FormatText
+	var period = "week"
+	var periods = 8
+
+	flag.Usage = usage
+	flag.StringVar(&format, "format", format, "Select the output format.")
+	flag.IntVar(&periods, "n", periods, "Number of periods to show.")
+	flag.StringVar(&period, "period", period, "Throughput period.")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		die.With("Workspace name is required.")
+	}
+
+	if format != workspace.FormatText && format != workspace.FormatJSON {
+		die.With("Unsupported output format %s.", format)
+	}
+
+	if periods < 1 {
+		die.With("At least one period is required.")
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), false)
+	die.If(err)
+
+	words, err := ws.ExpandQuery(flag.Args()[1:])
+	die.If(err)
+
+	// The time filters would select tasks on their completion
+	// dates, hiding the open backlog, so they only set the range
+	// for the throughput.
+	dates, words := workspace.SplitRange(words)
+	r, err := workspace.ProcessQuery(dates, workspace.StatusAny)
+	die.If(err)
+	c, err := workspace.ProcessQuery(words, workspace.StatusAny)
+	die.If(err)
+
+	end := r.End()
+	if end.IsZero() {
+		end = ws.Today()
+	}
+
+	start := r.Start()
+	if start.IsZero() {
+		if period == "week" {
+			start = end.AddDate(0, 0, -7*(periods-1))
+		} else {
+			start = end.AddDate(0, 0, -(periods - 1))
+		}
+	}
+
+	stats, err := ws.ComputeStats(c.Filter(ws.Tasks), period, start, end)
+	die.If(err)
+
+	if format == workspace.FormatJSON {
+		out, err := json.MarshalIndent(stats, "", "    ")
+		die.If(err)
+		fmt.Println(string(out))
+		return
+	}
+
+	printText(stats)
+}
@@ -27,26 +27,40 @@ func usage() {
 	fmt.Printf(`%s is a utility to tag tasks.
 
 Usage:
-%s [-f file] [-h] [-i] workspace
+%s [-d] [-f file] [-h] [-i] [-l] [-sort keys] workspace
 
 Flags:
+    -d                       Remove tags from tasks instead of adding them.
     -h                       Print this usage message.
+    -l                       List the workspace's tags as a tree, with
+                             the number of tasks under each tag.
+    -sort keys               Sort tasks by the given keys.
 
 Tags should be entered as a comma separated list, e.g.
 
     tag1, tag2
 
-Whitespace between tags is ignored.
-`, name, name)
+Whitespace between tags is ignored. Tags may be organised into a
+hierarchy by separating levels with a slash, e.g. client/acme/billing;
+filtering on t:client/acme selects all of the tags beneath it.
+
+To rename, merge or delete a tag across every task, use util37-retag.
+
+%s
+`, name, name, workspace.SortUsage)
 }
 
 func main() {
-	var shouldInit bool
+	var shouldInit, list, remove bool
 	var fromFile string
+	var sortSpec string
 
 	flag.Usage = usage
+	flag.BoolVar(&remove, "d", false, "Remove tags instead of adding them.")
 	flag.StringVar(&fromFile, "f", "", "Read annotations from a file.")
 	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
+	flag.BoolVar(&list, "l", false, "List the workspace's tags.")
+	flag.StringVar(&sortSpec, "sort", "", "Sort tasks by the given keys.")
 	flag.Parse()
 
 	if flag.NArg() == 0 {
@@ -56,8 +70,16 @@ func main() {
 	ws, err := workspace.ReadFile(flag.Arg(0), shouldInit)
 	die.If(err)
 
+	if list {
+		ws.TagTree().Write(os.Stdout)
+		return
+	}
+
 	entryID := ws.NewEntry()
-	tasks := ws.EntryTasks(entryID).Unfinished().Sort()
+	sorter, err := workspace.LoadEntrySort(sortSpec)
+	die.If(err)
+	sorter = sorter.WithOrder(ws.Entries[entryID].Tasks)
+	tasks := ws.EntryTasks(entryID).Unfinished().SortBy(sorter)
 
 	for {
 		fmt.Println("Today's TODO:")
@@ -84,11 +106,19 @@ func main() {
 
 		task := tasks[idx]
 		fmt.Println("Current tags:", task.TagString())
-		fmt.Printf("Tags to be added: ")
+		if remove {
+			fmt.Printf("Tags to be removed: ")
+		} else {
+			fmt.Printf("Tags to be added: ")
+		}
 		line = readline()
 		tags := workspace.Tokenize(line, ",")
 		for i := range tags {
-			ws.Tag(task.ID, tags[i])
+			if remove {
+				ws.Untag(task.ID, tags[i])
+			} else {
+				ws.Tag(task.ID, tags[i])
+			}
 		}
 
 		err = workspace.WriteFile(ws)
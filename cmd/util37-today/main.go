@@ -15,44 +15,84 @@ func usage() {
 	fmt.Printf(`%s is a utility to report the unfinished tasks for the day.
 
 Usage:
-%s [-i] [-l] [-m] [-p priority] workspace [search string]
+%s [-format fmt] [-group by] [-i] [-l] [-m] [-p priority]
+    [-sort keys] [-template file] workspace [search string]
 
 Flags:
+    -format fmt         Select the output format: text, markdown, json,
+                        ndjson or html.
+    -group by           Group tasks in HTML output by tag, priority or
+                        none (the default).
     -h                   Print this usage message.
     -i                  Initialise a new workspace if needed.
     -l                  Print task annotations (long format).
     -m                  Display tasks in markdown format.
+    -sort keys          Sort tasks by the given keys.
+    -template file      Format the text or markdown output with the
+                        Go text/template in file.
+
+Tasks that have been carried over from previous days' lists are marked
+with the number of times they've been carried over, e.g. [carried 4];
+see util37-triage for dealing with stale tasks.
 
 The query should follow the filter language:
 %s
-`, name, name, workspace.FilterUsage)
+
+%s
+
+%s
+`, name, name, workspace.FilterUsage, workspace.SortUsage, workspace.FormatUsage)
 }
 
-func asMarkdown(tasks []*workspace.Task, long bool) {
-	fmt.Printf("## TODO %s (%d tasks)\n",
-		workspace.Today().Format(workspace.DateFormat),
-		len(tasks),
-	)
+// splitFocus separates the focus tasks for the entry from the rest of
+// the tasks, keeping the focus tasks in the entry's order.
+func splitFocus(ws *workspace.Workspace, entryID uint64, tasks []*workspace.Task) ([]*workspace.Task, []*workspace.Task) {
+	selected := map[uint64]bool{}
+	for _, task := range tasks {
+		selected[task.ID] = true
+	}
+
+	var focus []*workspace.Task
+	inFocus := map[uint64]bool{}
+	for _, task := range ws.FocusTasks(entryID) {
+		if selected[task.ID] {
+			focus = append(focus, task)
+			inFocus[task.ID] = true
+		}
+	}
 
+	var rest []*workspace.Task
 	for _, task := range tasks {
-		fmt.Printf("#### %s\n", task)
-		if long {
-			for _, note := range task.Notes {
-				fmt.Println(workspace.Wrap("+ "+note, "", 72))
-			}
+		if !inFocus[task.ID] {
+			rest = append(rest, task)
 		}
 	}
+
+	return focus, rest
 }
 
 func main() {
 	var shouldInit, long, markdown bool
+	var sortSpec string
+	var format = workspace.FormatText
+	var tmplFile string
+	var group = workspace.GroupNone
 
 	flag.Usage = usage
 	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
 	flag.BoolVar(&long, "l", false, "Show annotations of each task.")
 	flag.BoolVar(&markdown, "m", false, "Print log as markdown.")
+	flag.StringVar(&sortSpec, "sort", "", "Sort tasks by the given keys.")
+	flag.StringVar(&format, "format", format, "Select the output format.")
+	flag.StringVar(&tmplFile, "template", "", "Format the output with a template.")
+	flag.StringVar(&group, "group", group, "Group tasks in HTML output.")
 	flag.Parse()
 
+	if markdown {
+		format = workspace.FormatMarkdown
+	}
+	die.If(workspace.CheckFormat(format))
+
 	if flag.NArg() == 0 {
 		die.With("Workspace name is required.")
 	}
@@ -62,31 +102,42 @@ func main() {
 
 	var c *workspace.FilterChain
 	if flag.NArg() == 1 {
-		c, err = workspace.ProcessQuery([]string{}, workspace.StatusUncompleted)
+		c, err = ws.Query([]string{}, workspace.StatusUncompleted)
 	} else {
-		c, err = workspace.ProcessQuery(flag.Args()[1:], workspace.StatusUncompleted)
+		c, err = ws.Query(flag.Args()[1:], workspace.StatusUncompleted)
 	}
 	die.If(err)
 
 	entryID := ws.NewEntry()
-	tasks := c.Filter(ws.EntryTasks(entryID)).Sort()
-	if markdown {
-		asMarkdown(tasks, long)
-	} else {
-		fmt.Printf("TODO %s (%d tasks):\n",
-			workspace.Today().Format(workspace.DateFormat),
-			len(tasks))
-		for _, task := range tasks {
-			fmt.Println("\t", task)
-			if long {
-				if len(task.Tags) > 0 {
-					fmt.Printf("\t\tTags: %s\n", task.TagString())
-				}
-
-				for _, note := range task.Notes {
-					fmt.Println(workspace.Wrap("+ "+note, "\t\t", 72))
-				}
-			}
-		}
+	sorter, err := workspace.LoadEntrySort(sortSpec)
+	die.If(err)
+	sorter = sorter.WithOrder(ws.Entries[entryID].Tasks)
+	tasks := c.Filter(ws.EntryTasks(entryID)).SortBy(sorter)
+	focus, tasks := splitFocus(ws, entryID, tasks)
+	switch format {
+	case workspace.FormatJSON, workspace.FormatNDJSON:
+		r := workspace.NewReport("today", ws, c, append(focus, tasks...))
+		die.If(r.Write(os.Stdout, format))
+	case workspace.FormatHTML:
+		title := "TODO " + ws.Today().Format(workspace.DateFormat)
+		r, err := workspace.NewHTMLReport(title, ws, c, append(focus, tasks...), group)
+		die.If(err)
+		die.If(r.WriteHTML(os.Stdout))
+	default:
+		tmpl, err := workspace.LoadTemplate("today", format, ws.Name, tmplFile)
+		die.If(err)
+
+		err = tmpl.Execute(os.Stdout, &workspace.TemplateData{
+			Workspace: ws.Name,
+			Report:    "today",
+			Date:      ws.Today(),
+			Range:     c.TimeRange(),
+			Long:      long,
+			Count:     len(focus) + len(tasks),
+			Focus:     focus,
+			Tasks:     tasks,
+			Carried:   ws.CarryCounts(),
+		})
+		die.If(err)
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/kisom/goutils/die"
 	"github.com/kisom/utility37/workspace"
@@ -17,21 +18,29 @@ func usage() {
 	fmt.Printf(`%s is a utility to add new tasks.
 
 Usage:
-%s [-h] [-i] [-p priority] workspace
+%s [-d day] [-h] [-i] [-p priority] [-sort keys] [-t tags] [-w day] workspace
 
 Flags:
+    -d day                   Tasks will be added due on the given day,
+                             in the same forms as -w.
     -h                       Print this usage message.
     -i                       Initialise a new workspace if needed.
     -p priority              Tasks will be added with the specified priority.
     -t tags                  List of comma-separated tags to apply to new 
                              tasks.
+    -sort keys               Sort tasks by the given keys.
+    -w day                   Schedule new tasks to start on the given
+                             day; see util37-snooze for the forms the
+                             day may take.
 
 %s
 
 When run, %s will display the current list of tasks, both completed
 and unfinished. A one-line task title should be entered, or an empty
 line to exit. This cycle will repeat until an empty line is entered.
-`, name, name, workspace.PriorityStrings, name)
+
+%s
+`, name, name, workspace.PriorityStrings, name, workspace.SortUsage)
 }
 
 var stdin = bufio.NewReader(os.Stdin)
@@ -47,11 +56,17 @@ func main() {
 	var shouldInit bool
 	var flagTags string
 	var priority = workspace.PriorityNormal.String()
+	var sortSpec string
+	var wait string
+	var due string
 
 	flag.Usage = usage
 	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
 	flag.StringVar(&priority, "p", priority, "Specify the priority for new tasks.")
 	flag.StringVar(&flagTags, "t", "", "Specify tags to be applied to new tasks.")
+	flag.StringVar(&sortSpec, "sort", "", "Sort tasks by the given keys.")
+	flag.StringVar(&due, "d", "", "Specify the due date for new tasks.")
+	flag.StringVar(&wait, "w", "", "Schedule new tasks to start on a later day.")
 	flag.Parse()
 
 	if flag.NArg() == 0 {
@@ -70,13 +85,28 @@ func main() {
 	ws, err := workspace.ReadFile(flag.Arg(0), shouldInit)
 	die.If(err)
 
-	entryID := ws.NewEntry()
-	entry := ws.Entries[entryID]
+	var start time.Time
+	if wait != "" {
+		start, err = ws.ParseWait(wait)
+		die.If(err)
+	}
+
+	var dueDay time.Time
+	if due != "" {
+		dueDay, err = ws.ParseWait(due)
+		die.If(err)
+	}
+
+	sorter, err := workspace.LoadEntrySort(sortSpec)
+	die.If(err)
 
 	for {
-		tasks := ws.EntryTasks(entryID).Sort()
+		// The day may change while tasks are being entered.
+		entryID := ws.NewEntry()
+		entry := ws.Entries[entryID]
+		tasks := ws.EntryTasks(entryID).SortBy(sorter.WithOrder(entry.Tasks))
 		fmt.Printf("TODO %s (%d tasks):\n",
-			workspace.Today().Format(workspace.DateFormat),
+			ws.Today().Format(workspace.DateFormat),
 			len(tasks))
 		for _, task := range tasks {
 			fmt.Println(task)
@@ -91,9 +121,15 @@ func main() {
 		id := workspace.NewTaskID()
 		task := workspace.NewTask(id, line)
 		task.Priority = pri
-		entry.Tasks = append(entry.Tasks, id)
+		task.Due = dueDay
 		ws.Tasks[id] = task
-		ws.Entries[entryID] = entry
+		entry.Tasks = append(entry.Tasks, id)
+		if !start.IsZero() {
+			ws.Snooze(id, start)
+			if task.Waiting(ws.Today()) {
+				fmt.Println("Scheduled to start", start.Format(workspace.DateFormat))
+			}
+		}
 
 		for i := range tags {
 			ws.Tag(task.ID, tags[i])
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a utility to deal with stale tasks in bulk.
+
+Usage:
+%s [-h] [-n count] workspace [query...]
+
+Flags:
+    -h                       Print this usage message.
+    -n count                 Treat tasks carried over from one day's
+                             list to the next at least count times as
+                             stale (default 7).
+
+When run, %s will display the numbered list of stale tasks, most
+carried over first. Select tasks by number, as a comma-separated list
+of numbers and ranges (e.g. 0,2,5-7), or "all"; then enter an action:
+
+    c                        Complete the tasks.
+    x                        Cancel the tasks.
+    s day                    Snooze the tasks until the day, given as
+                             YYYY-MM-DD, "tomorrow", a weekday, or a
+                             time from now, such as 3d, 2w or 1m.
+    p priority               Set the tasks' priority.
+
+An empty line exits.
+
+%s
+
+The query should follow the filter language:
+%s
+`, name, name, name, workspace.PriorityStrings, workspace.FilterUsage)
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func readline() string {
+	line, err := stdin.ReadString('\n')
+	die.If(err)
+
+	return strings.TrimSpace(line)
+}
+
+// selection parses a list of task numbers and ranges, such as
+// "0,2,5-7", or "all".
+func selection(line string, n int) ([]int, error) {
+	if line == "all" {
+		var sel = make([]int, n)
+		for i := range sel {
+			sel[i] = i
+		}
+		return sel, nil
+	}
+
+	var sel []int
+	for _, part := range strings.Split(line, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %s", part)
+		}
+
+		end, err := strconv.Atoi(hi)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %s", part)
+		}
+
+		if start < 0 || end >= n || start > end {
+			return nil, fmt.Errorf("selection %s is out of range", part)
+		}
+
+		for i := start; i <= end; i++ {
+			sel = append(sel, i)
+		}
+	}
+	return sel, nil
+}
+
+// apply carries out an action on the selected tasks.
+func apply(ws *workspace.Workspace, tasks []*workspace.Task, action string) error {
+	fields := strings.Fields(action)
+	if len(fields) == 0 {
+		return fmt.Errorf("no action given")
+	}
+
+	switch fields[0] {
+	case "c":
+		for _, task := range tasks {
+			task.MarkDone()
+		}
+	case "x":
+		for _, task := range tasks {
+			task.MarkCancelled()
+		}
+	case "s":
+		if len(fields) != 2 {
+			return fmt.Errorf("snooze needs a day")
+		}
+
+		until, err := ws.ParseWait(fields[1])
+		if err != nil {
+			return err
+		}
+
+		for _, task := range tasks {
+			ws.Snooze(task.ID, until)
+		}
+	case "p":
+		if len(fields) != 2 {
+			return fmt.Errorf("priority needs a value")
+		}
+
+		pri := workspace.PriorityFromString(fields[1])
+		if pri == workspace.PriorityUnknown {
+			return fmt.Errorf("invalid priority %s", fields[1])
+		}
+
+		for _, task := range tasks {
+			task.Priority = pri
+		}
+	default:
+		return fmt.Errorf("unknown action %s", fields[0])
+	}
+
+	return nil
+}
+
+func main() {
+	var stale int
+
+	flag.Usage = usage
+	flag.IntVar(&stale, "n", 7, "Number of carries after which a task is stale.")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		die.With("Workspace name is required.")
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), false)
+	die.If(err)
+
+	c, err := ws.Query(flag.Args()[1:], workspace.StatusUncompleted)
+	die.If(err)
+
+	ws.NewEntry()
+	for {
+		carried := ws.CarryCounts()
+		tasks := c.Filter(ws.Stale(stale)).Sort()
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return carried[tasks[i].ID] > carried[tasks[j].ID]
+		})
+
+		if len(tasks) == 0 {
+			fmt.Println("No stale tasks.")
+			break
+		}
+
+		fmt.Printf("Stale tasks (%d):\n", len(tasks))
+		for i, task := range tasks {
+			fmt.Printf("%d [carried %d] %s\n", i, carried[task.ID], task)
+		}
+
+		fmt.Printf("Tasks: ")
+		line := readline()
+		if line == "" {
+			break
+		}
+
+		sel, err := selection(line, len(tasks))
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		var selected []*workspace.Task
+		for _, i := range sel {
+			selected = append(selected, tasks[i])
+		}
+
+		fmt.Printf("(c)omplete, cancel (x), (s)nooze day, (p)riority: ")
+		if err = apply(ws, selected, readline()); err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		err = workspace.WriteFile(ws)
+		die.If(err)
+	}
+}
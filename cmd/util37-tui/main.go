@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"github.com/kisom/goutils/die"
+	"github.com/kisom/utility37/workspace"
+)
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Printf(`%s is a full-screen interface to today's tasks.
+
+Usage:
+%s [-h] [-i] [-sort keys] workspace [query...]
+
+Flags:
+    -h                       Print this usage message.
+    -i                       Initialise a new workspace if needed.
+    -sort keys               Sort tasks by the given keys.
+
+%s lists today's tasks, both completed and unfinished, with the
+selected task's details, notes and history beside or below the list.
+Tasks are completed, cancelled, prioritised, tagged and annotated with
+single keys, and the list can be filtered as you type with /, using
+the filter language; the query given on the command line is the
+initial filter. Changes are saved as they are made, on top of any
+changes other tools have made since the workspace was read. Press ?
+for the keys.
+
+%s needs a terminal, and uses stty to set it up.
+
+The query should follow the filter language:
+%s
+
+%s
+`, name, name, name, name, workspace.FilterUsage, workspace.SortUsage)
+}
+
+func main() {
+	var shouldInit bool
+	var sortSpec string
+
+	flag.Usage = usage
+	flag.BoolVar(&shouldInit, "i", false, "Initialise new workspace if needed.")
+	flag.StringVar(&sortSpec, "sort", "", "Sort tasks by the given keys.")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		die.With("Workspace name is required.")
+	}
+
+	ws, err := workspace.ReadFile(flag.Arg(0), shouldInit)
+	die.If(err)
+
+	sorter, err := workspace.LoadEntrySort(sortSpec)
+	die.If(err)
+
+	u := &ui{ws: ws, sorter: sorter}
+	u.setFilter(strings.Join(flag.Args()[1:], " "))
+	if u.chain == nil {
+		die.With("%s", u.message)
+	}
+
+	u.term, err = openTerminal()
+	die.If(err)
+
+	err = runUI(u)
+	die.If(err)
+}
+
+// runUI runs the interface, restoring the terminal when it returns,
+// even if it panics, so that the shell is left usable and the error
+// can be read.
+func runUI(u *ui) (err error) {
+	defer func() {
+		u.term.Close()
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v\n%s", r, debug.Stack())
+		}
+	}()
+
+	return u.run()
+}
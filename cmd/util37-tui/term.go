@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// A terminal reads single keys and draws full screens. The terminal's
+// modes are set with stty, rather than a terminal library.
+type terminal struct {
+	saved string
+	out   *bufio.Writer
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// openTerminal switches to the alternate screen and turns off line
+// editing and echoing, along with the keys for signals, so that ^C
+// can be read as a key.
+func openTerminal() (*terminal, error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, errors.New("standard input isn't a terminal")
+	}
+
+	_, err = stty("-icanon", "-echo", "-isig", "-ixon", "min", "1", "time", "0")
+	if err != nil {
+		stty(saved)
+		return nil, err
+	}
+
+	t := &terminal{saved: saved, out: bufio.NewWriter(os.Stdout)}
+	t.out.WriteString("\x1b[?1049h\x1b[?25l")
+	return t, t.out.Flush()
+}
+
+// Close restores the terminal.
+func (t *terminal) Close() error {
+	t.out.WriteString("\x1b[?25h\x1b[?1049l")
+	t.out.Flush()
+	_, err := stty(t.saved)
+	return err
+}
+
+// size returns the number of rows and columns, assuming 24x80 if they
+// can't be found.
+func (t *terminal) size() (int, int) {
+	out, err := stty("size")
+	if err == nil {
+		fields := strings.Fields(out)
+		if len(fields) == 2 {
+			rows, rerr := strconv.Atoi(fields[0])
+			cols, cerr := strconv.Atoi(fields[1])
+			if rerr == nil && cerr == nil && rows > 0 && cols > 0 {
+				return rows, cols
+			}
+		}
+	}
+	return 24, 80
+}
+
+// Keys that aren't characters are given negative values.
+const (
+	keyUp rune = -(iota + 1)
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEscape
+	keyEnter
+	keyBackspace
+	keyUnknown
+)
+
+const keyInterrupt = 3 // ^C
+
+var escapes = map[string]rune{
+	"\x1b[A":  keyUp,
+	"\x1bOA":  keyUp,
+	"\x1b[B":  keyDown,
+	"\x1bOB":  keyDown,
+	"\x1b[5~": keyPageUp,
+	"\x1b[6~": keyPageDown,
+	"\x1b[H":  keyHome,
+	"\x1bOH":  keyHome,
+	"\x1b[1~": keyHome,
+	"\x1b[F":  keyEnd,
+	"\x1bOF":  keyEnd,
+	"\x1b[4~": keyEnd,
+}
+
+// readKeys reads the keys pressed, or pasted, since the last read.
+func (t *terminal) readKeys() ([]rune, error) {
+	var buf [256]byte
+	n, err := os.Stdin.Read(buf[:])
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(buf[:n])
+	switch {
+	case s == "\x1b":
+		return []rune{keyEscape}, nil
+	case strings.HasPrefix(s, "\x1b"):
+		if key, ok := escapes[s]; ok {
+			return []rune{key}, nil
+		}
+		return []rune{keyUnknown}, nil
+	}
+
+	var keys []rune
+	for _, r := range s {
+		switch r {
+		case '\r', '\n':
+			r = keyEnter
+		case '\x7f', '\b':
+			r = keyBackspace
+		}
+		keys = append(keys, r)
+	}
+	return keys, nil
+}
+
+// draw replaces the screen with the given lines.
+func (t *terminal) draw(lines []string) error {
+	t.out.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			t.out.WriteString("\r\n")
+		}
+		t.out.WriteString(line)
+		t.out.WriteString("\x1b[K")
+	}
+	t.out.WriteString("\x1b[J")
+	return t.out.Flush()
+}
@@ -0,0 +1,664 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kisom/utility37/workspace"
+)
+
+type mode int
+
+const (
+	modeNormal mode = iota
+	modeFilter
+	modePrompt
+)
+
+const (
+	reverse = "\x1b[7m"
+	dim     = "\x1b[2m"
+	bold    = "\x1b[1m"
+	reset   = "\x1b[0m"
+)
+
+const keyHelp = "j/k move  c complete  x cancel  o reopen  1-4 priority  t tag  a annotate  n new  / filter  ? help  q quit"
+
+var helpText = []string{
+	"Keys:",
+	"",
+	"  j, k, arrows    move up and down",
+	"  g, G            go to the first or last task",
+	"  PgUp, PgDn      move a page at a time",
+	"  c, space        complete the task",
+	"  x               cancel the task",
+	"  o               reopen a completed or cancelled task",
+	"  1, 2, 3, 4      set the priority to urgent, high, normal or low",
+	"  p               raise the priority, wrapping around",
+	"  t               tag the task; tags are separated by commas,",
+	"                  and tags beginning with - are removed",
+	"  a               annotate the task",
+	"  n               add a new task to today's list",
+	"  /               filter the tasks with the filter language, as",
+	"                  you type; Enter keeps the filter, Esc clears it",
+	"  r               reload the workspace from disk",
+	"  ?               show or hide this help",
+	"  q, ^C           quit",
+}
+
+// A ui is the state of the interface.
+type ui struct {
+	ws     *workspace.Workspace
+	term   *terminal
+	sorter *workspace.Sorter
+
+	tasks    []*workspace.Task
+	selected int
+	offset   int
+
+	filter string
+	chain  *workspace.FilterChain
+
+	mode    mode
+	prompt  string
+	input   string
+	onInput func(string)
+
+	message string
+	help    bool
+	quit    bool
+
+	rows, cols int
+}
+
+// load rebuilds the list of today's tasks that match the filter,
+// keeping the selected task selected.
+func (u *ui) load() {
+	var current uint64
+	if task := u.current(); task != nil {
+		current = task.ID
+	}
+
+	entryID := u.ws.NewEntry()
+	entry := u.ws.Entries[entryID]
+	tasks := u.ws.EntryTasks(entryID)
+	if u.chain != nil {
+		tasks = u.chain.Filter(tasks)
+	}
+	// Tasks that are no longer in the workspace are skipped.
+	u.tasks = nil
+	for _, task := range tasks.SortBy(u.sorter.WithOrder(entry.Tasks)) {
+		if task != nil {
+			u.tasks = append(u.tasks, task)
+		}
+	}
+
+	u.selected = 0
+	for i, task := range u.tasks {
+		if task.ID == current {
+			u.selected = i
+		}
+	}
+}
+
+func (u *ui) current() *workspace.Task {
+	if u.selected < 0 || u.selected >= len(u.tasks) {
+		return nil
+	}
+	return u.tasks[u.selected]
+}
+
+// setFilter parses the filter, keeping the last valid filter if it
+// can't be parsed.
+func (u *ui) setFilter(filter string) {
+	u.filter = filter
+	c, err := u.ws.Query(strings.Fields(filter), workspace.StatusAny)
+	if err != nil {
+		u.message = err.Error()
+		return
+	}
+
+	u.message = ""
+	u.chain = c
+	u.load()
+}
+
+// change applies fn to the workspace and saves it. If another program
+// has written the workspace since it was read, it is read again and fn
+// is applied to the new contents, so that neither change is lost.
+func (u *ui) change(message string, fn func(*workspace.Workspace) error) {
+	var err error
+	reread := false
+	for attempt := 0; attempt < 3; attempt++ {
+		if err = fn(u.ws); err != nil {
+			break
+		}
+
+		err = workspace.WriteFile(u.ws)
+		if err != workspace.ErrChanged {
+			break
+		}
+
+		ws, rerr := workspace.ReadFile(u.ws.Name, false)
+		if rerr != nil {
+			err = rerr
+			break
+		}
+		u.ws, reread = ws, true
+	}
+
+	if reread {
+		// The filter refers to the workspace it was parsed for.
+		u.setFilter(u.filter)
+	}
+	u.load()
+
+	u.message = message
+	if err != nil {
+		u.message = "Couldn't save: " + err.Error()
+	}
+}
+
+// changeTask applies fn to the task with the given ID, as change does.
+func (u *ui) changeTask(id uint64, message string, fn func(*workspace.Workspace, *workspace.Task)) {
+	u.change(message, func(ws *workspace.Workspace) error {
+		task := ws.Tasks[id]
+		if task == nil {
+			return fmt.Errorf("task %d is no longer in the workspace", id)
+		}
+
+		fn(ws, task)
+		return nil
+	})
+}
+
+func (u *ui) ask(prompt string, fn func(string)) {
+	u.mode, u.prompt, u.input, u.onInput = modePrompt, prompt, "", fn
+}
+
+func (u *ui) move(n int) {
+	u.selected += n
+	if u.selected >= len(u.tasks) {
+		u.selected = len(u.tasks) - 1
+	}
+	if u.selected < 0 {
+		u.selected = 0
+	}
+}
+
+// edit applies a key to a line of input, returning false if the key
+// doesn't edit the line.
+func edit(line *string, key rune) bool {
+	switch {
+	case key == keyBackspace:
+		r := []rune(*line)
+		if len(r) > 0 {
+			*line = string(r[:len(r)-1])
+		}
+	case key >= ' ':
+		*line += string(key)
+	default:
+		return false
+	}
+	return true
+}
+
+func (u *ui) handle(key rune) {
+	if key == keyInterrupt {
+		u.quit = true
+		return
+	}
+
+	switch u.mode {
+	case modeFilter:
+		u.handleFilter(key)
+	case modePrompt:
+		u.handlePrompt(key)
+	default:
+		u.handleNormal(key)
+	}
+}
+
+func (u *ui) handleFilter(key rune) {
+	switch key {
+	case keyEnter:
+		u.mode = modeNormal
+	case keyEscape:
+		u.mode = modeNormal
+		u.setFilter("")
+	default:
+		filter := u.filter
+		if edit(&filter, key) {
+			u.setFilter(filter)
+		}
+	}
+}
+
+func (u *ui) handlePrompt(key rune) {
+	switch key {
+	case keyEnter:
+		u.mode = modeNormal
+		if input := strings.TrimSpace(u.input); input != "" {
+			u.onInput(input)
+		}
+	case keyEscape:
+		u.mode = modeNormal
+	default:
+		edit(&u.input, key)
+	}
+}
+
+var priorityKeys = map[rune]workspace.Priority{
+	'1': workspace.PriorityUrgent,
+	'2': workspace.PriorityHigh,
+	'3': workspace.PriorityNormal,
+	'4': workspace.PriorityLow,
+}
+
+var nextPriority = map[workspace.Priority]workspace.Priority{
+	workspace.PriorityUnknown: workspace.PriorityNormal,
+	workspace.PriorityLow:     workspace.PriorityNormal,
+	workspace.PriorityNormal:  workspace.PriorityHigh,
+	workspace.PriorityHigh:    workspace.PriorityUrgent,
+	workspace.PriorityUrgent:  workspace.PriorityLow,
+}
+
+func (u *ui) handleNormal(key rune) {
+	_, rows := u.listSize()
+	switch key {
+	case 'q':
+		u.quit = true
+		return
+	case 'j', keyDown:
+		u.move(1)
+		return
+	case 'k', keyUp:
+		u.move(-1)
+		return
+	case keyPageDown:
+		u.move(rows)
+		return
+	case keyPageUp:
+		u.move(-rows)
+		return
+	case 'g', keyHome:
+		u.selected = 0
+		return
+	case 'G', keyEnd:
+		u.move(len(u.tasks))
+		return
+	case '/':
+		u.mode = modeFilter
+		return
+	case '?':
+		u.help = !u.help
+		return
+	case 'n':
+		u.ask("New task: ", u.add)
+		return
+	case 'r':
+		u.reload()
+		return
+	}
+
+	task := u.current()
+	if task == nil {
+		return
+	}
+
+	id := task.ID
+	if pri, ok := priorityKeys[key]; ok {
+		u.changeTask(id, "Priority set to "+pri.String()+".", func(ws *workspace.Workspace, task *workspace.Task) {
+			task.Priority = pri
+		})
+		return
+	}
+
+	switch key {
+	case 'c', ' ':
+		if !task.Done {
+			u.changeTask(id, "Completed: "+task.Title, func(ws *workspace.Workspace, task *workspace.Task) {
+				if !task.Done {
+					task.MarkDone()
+				}
+			})
+		}
+	case 'x':
+		if !task.Done {
+			u.changeTask(id, "Cancelled: "+task.Title, func(ws *workspace.Workspace, task *workspace.Task) {
+				if !task.Done {
+					task.MarkCancelled()
+				}
+			})
+		}
+	case 'o':
+		if task.Done {
+			u.changeTask(id, "Reopened: "+task.Title, func(ws *workspace.Workspace, task *workspace.Task) {
+				task.Done, task.Cancelled = false, false
+				task.Finished = time.Time{}
+			})
+		}
+	case 'p':
+		pri := nextPriority[task.Priority]
+		u.changeTask(id, "Priority set to "+pri.String()+".", func(ws *workspace.Workspace, task *workspace.Task) {
+			task.Priority = pri
+		})
+	case 't':
+		u.ask("Tags: ", func(input string) { u.tag(task, input) })
+	case 'a':
+		u.ask("Note: ", func(input string) {
+			u.changeTask(id, "Annotated: "+task.Title, func(ws *workspace.Workspace, task *workspace.Task) {
+				task.Notes = append(task.Notes, input)
+			})
+		})
+	}
+}
+
+func (u *ui) add(title string) {
+	var id uint64
+	u.change("Added: "+title, func(ws *workspace.Workspace) error {
+		entryID := ws.NewEntry()
+		id = workspace.NewTaskID()
+		for ws.Tasks[id] != nil {
+			id++
+		}
+
+		ws.Tasks[id] = workspace.NewTask(id, title)
+		entry := ws.Entries[entryID]
+		entry.Tasks = append(entry.Tasks, id)
+		return nil
+	})
+
+	for i := range u.tasks {
+		if u.tasks[i].ID == id {
+			u.selected = i
+		}
+	}
+}
+
+func (u *ui) tag(task *workspace.Task, input string) {
+	u.changeTask(task.ID, "Tagged: "+task.Title, func(ws *workspace.Workspace, task *workspace.Task) {
+		for _, tag := range workspace.Tokenize(input, ",") {
+			if strings.HasPrefix(tag, "-") {
+				ws.Untag(task.ID, strings.TrimPrefix(tag, "-"))
+			} else {
+				ws.Tag(task.ID, tag)
+			}
+		}
+	})
+}
+
+// reload reads the workspace again, to see changes made elsewhere.
+func (u *ui) reload() {
+	ws, err := workspace.ReadFile(u.ws.Name, false)
+	if err != nil {
+		u.message = "Couldn't reload: " + err.Error()
+		return
+	}
+
+	u.ws = ws
+	u.setFilter(u.filter)
+	if u.message == "" {
+		u.message = "Reloaded."
+	}
+}
+
+// fit truncates or pads s to exactly width columns.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+// wrap splits s into lines no wider than width.
+func wrap(s, indent string, width int) []string {
+	var lines []string
+	line := indent
+	for _, word := range strings.Fields(s) {
+		if len([]rune(line))+len([]rune(word)) > width && strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+			line = indent
+		}
+		if strings.TrimSpace(line) != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, line)
+}
+
+// wide returns true if the detail pane is beside the list rather
+// than below it.
+func (u *ui) wide() bool {
+	return u.cols >= 100
+}
+
+// listSize returns the width and height of the list of tasks.
+func (u *ui) listSize() (int, int) {
+	body := u.rows - 3
+	if body < 0 {
+		body = 0
+	}
+
+	if u.wide() {
+		return u.cols * 3 / 5, body
+	}
+	return u.cols, body * 3 / 5
+}
+
+func taskLine(task *workspace.Task) string {
+	if task == nil {
+		return ""
+	}
+
+	marker := "[ ]"
+	if task.Cancelled {
+		marker = "[-]"
+	} else if task.Done {
+		marker = "[X]"
+	}
+
+	line := fmt.Sprintf("%s %s %s", marker, task.Priority, task.Title)
+	for _, tag := range task.Tags {
+		line += " #" + tag
+	}
+
+	if !task.Due.IsZero() && !task.Done {
+		line += " (due " + task.Due.Format(workspace.DateFormat) + ")"
+	}
+	return line
+}
+
+func (u *ui) listLines(width, height int) []string {
+	if u.selected < u.offset {
+		u.offset = u.selected
+	}
+	if u.selected >= u.offset+height {
+		u.offset = u.selected - height + 1
+	}
+
+	var lines []string
+	for i := u.offset; i < len(u.tasks) && len(lines) < height; i++ {
+		task := u.tasks[i]
+		line := fit(" "+taskLine(task), width)
+		switch {
+		case i == u.selected:
+			line = reverse + line + reset
+		case task.Done:
+			line = dim + line + reset
+		}
+		lines = append(lines, line)
+	}
+
+	if len(u.tasks) == 0 && height > 0 {
+		lines = append(lines, fit(" No tasks.", width))
+	}
+	for len(lines) < height {
+		lines = append(lines, strings.Repeat(" ", width))
+	}
+	return lines
+}
+
+// detailLines describes the selected task: its fields, its notes and
+// the days it has been on the list.
+func (u *ui) detailLines(width int) []string {
+	if u.help {
+		// draw fits the lines in place, so it is given a copy.
+		return append([]string(nil), helpText...)
+	}
+
+	task := u.current()
+	if task == nil {
+		return nil
+	}
+
+	lines := wrap(task.Title, "", width)
+	lines = append(lines, "")
+
+	status := "open"
+	if task.Cancelled {
+		status = "cancelled " + task.Finished.Format(workspace.DateFormat)
+	} else if task.Done {
+		status = "completed " + task.Finished.Format(workspace.DateFormat)
+	}
+	lines = append(lines,
+		"Status:   "+status,
+		"Priority: "+task.Priority.String(),
+		"Created:  "+task.Created.Format("2006-01-02 15:04"))
+
+	if len(task.Tags) > 0 {
+		lines = append(lines, wrap(strings.Join(task.Tags, ", "), "Tags:    ", width)...)
+	}
+	if !task.Due.IsZero() {
+		lines = append(lines, "Due:      "+task.Due.Format(workspace.DateFormat))
+	}
+	if task.Waiting(u.ws.Today()) {
+		lines = append(lines, "Waiting:  until "+task.Wait.Format(workspace.DateFormat))
+	}
+
+	if len(task.Notes) > 0 {
+		lines = append(lines, "", "Notes:")
+		for _, note := range task.Notes {
+			noteLines := wrap(note, "   ", width)
+			noteLines[0] = " -" + strings.TrimPrefix(noteLines[0], "  ")
+			lines = append(lines, noteLines...)
+		}
+	}
+
+	entries := u.ws.TaskEntries(task.ID)
+	lines = append(lines, "", "History:")
+	if len(entries) == 0 {
+		return append(lines, "  Not on any day's list.")
+	}
+
+	first := u.ws.EntryDay(entries[0])
+	lines = append(lines,
+		fmt.Sprintf("  On %d days' lists since %s, carried over %d times.",
+			len(entries), first.Format(workspace.DateFormat),
+			u.ws.CarryCounts()[task.ID]))
+
+	if len(entries) > 7 {
+		entries = entries[len(entries)-7:]
+	}
+	var days []string
+	for _, eid := range entries {
+		days = append(days, u.ws.EntryDay(eid).Format("Mon 01-02"))
+	}
+	return append(lines, wrap(strings.Join(days, ", "), "  Recently:", width)...)
+}
+
+func (u *ui) statusLine(width int) string {
+	switch {
+	case u.mode == modeFilter:
+		return fit("/"+u.filter+"█  "+u.message, width)
+	case u.mode == modePrompt:
+		return fit(u.prompt+u.input+"█", width)
+	case u.message != "":
+		return fit(u.message, width)
+	case u.filter != "":
+		return fit("Filter: "+u.filter, width)
+	}
+	return ""
+}
+
+func (u *ui) draw() error {
+	u.rows, u.cols = u.term.size()
+	lw, lh := u.listSize()
+
+	open := 0
+	for _, task := range u.tasks {
+		if task != nil && !task.Done {
+			open++
+		}
+	}
+
+	header := fmt.Sprintf(" util37 — %s — %s — %d of %d open", u.ws.Name,
+		u.ws.Today().Format(workspace.DateFormat), open, len(u.tasks))
+	lines := []string{reverse + fit(header, u.cols) + reset}
+
+	// The detail pane fills the rest of the body, beside or below
+	// the list, with the task's title in bold.
+	list := u.listLines(lw, lh)
+	dw, dh := u.cols-lw-3, lh
+	if !u.wide() {
+		dw, dh = u.cols-2, u.rows-3-lh-1
+	}
+	if dh < 0 {
+		// There is no room for the details on a very short
+		// terminal.
+		dh = 0
+	}
+
+	detail := u.detailLines(dw)
+	for i := range detail {
+		detail[i] = strings.TrimRight(fit(detail[i], dw), " ")
+	}
+	if len(detail) > 0 && !u.help {
+		detail[0] = bold + detail[0] + reset
+	}
+	for len(detail) < dh {
+		detail = append(detail, "")
+	}
+
+	if u.wide() {
+		for i, line := range list {
+			lines = append(lines, line+dim+" │ "+reset+detail[i])
+		}
+	} else {
+		lines = append(lines, list...)
+		lines = append(lines, dim+strings.Repeat("─", u.cols)+reset)
+		for _, line := range detail[:dh] {
+			lines = append(lines, " "+line)
+		}
+	}
+
+	lines = append(lines, u.statusLine(u.cols), dim+fit(keyHelp, u.cols)+reset)
+	return u.term.draw(lines)
+}
+
+// run draws the interface and handles keys until the user quits.
+func (u *ui) run() error {
+	for !u.quit {
+		if err := u.draw(); err != nil {
+			return err
+		}
+
+		keys, err := u.term.readKeys()
+		if err != nil {
+			return err
+		}
+
+		u.message = ""
+		for _, key := range keys {
+			u.handle(key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CompletesTrailer is the commit message trailer that names the tasks
+// a commit completes, as in "Completes: util37#1234".
+const CompletesTrailer = "Completes"
+
+var (
+	trailerRegexp = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*)\s*:\s*(.*)$`)
+	taskRefRegexp = regexp.MustCompile(`^util37#(\d+)$`)
+)
+
+// A Trailer is a "Key: value" line at the end of a commit message.
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// messageLines returns the lines of a commit message as git would
+// record it, without comments, anything below a scissors line, or
+// trailing blank lines.
+func messageLines(msg string) []string {
+	var lines []string
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.HasPrefix(line, "# ") && strings.HasSuffix(line, " >8 ------------------------") {
+			break
+		} else if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// CommitSubject returns the first line of a commit message.
+func CommitSubject(msg string) string {
+	for _, line := range messageLines(msg) {
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// ParseTrailers returns the trailers in a commit message: the lines of
+// its last paragraph, if every line in it is a trailer or continues
+// the one before. The subject is never a trailer.
+func ParseTrailers(msg string) []Trailer {
+	lines := messageLines(msg)
+	start := len(lines)
+	for start > 0 && lines[start-1] != "" {
+		start--
+	}
+
+	body := false
+	for _, line := range lines[:start] {
+		if line != "" {
+			body = true
+			break
+		}
+	}
+
+	if !body {
+		return nil
+	}
+
+	var trailers []Trailer
+	for _, line := range lines[start:] {
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(trailers) == 0 {
+				return nil
+			}
+			trailers[len(trailers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		subs := trailerRegexp.FindStringSubmatch(line)
+		if subs == nil {
+			return nil
+		}
+		trailers = append(trailers, Trailer{Key: subs[1], Value: subs[2]})
+	}
+	return trailers
+}
+
+// CompletedIDs returns the IDs of the tasks named in a commit
+// message's Completes trailers, which may each name several tasks,
+// separated by commas or spaces.
+func CompletedIDs(msg string) ([]uint64, error) {
+	var ids []uint64
+	for _, trailer := range ParseTrailers(msg) {
+		if !strings.EqualFold(trailer.Key, CompletesTrailer) {
+			continue
+		}
+
+		refs := strings.FieldsFunc(trailer.Value, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		for _, ref := range refs {
+			subs := taskRefRegexp.FindStringSubmatch(ref)
+			if subs == nil {
+				return nil, errors.New("workspace: invalid task reference " + ref)
+			}
+
+			id, err := strconv.ParseUint(subs[1], 10, 64)
+			if err != nil {
+				return nil, errors.New("workspace: invalid task reference " + ref)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// CommitAnnotation returns the note added to tasks completed by a
+// commit; the hash may be empty if the commit hasn't been made yet.
+func CommitAnnotation(hash, subject string) string {
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+
+	if hash == "" {
+		return "Completed by commit: " + subject
+	}
+	return fmt.Sprintf("Completed by commit %s: %s", hash, subject)
+}
+
+// CompleteCommit marks the tasks with the given IDs as completed, if
+// they aren't already, and annotates them with the commit. An
+// annotation made before the commit had a hash, by a commit-msg hook,
+// is replaced by the one with the hash. It returns the tasks
+// annotated; if any of the IDs aren't tasks in the workspace, it
+// returns an error naming them without changing any task.
+func (ws *Workspace) CompleteCommit(ids []uint64, hash, subject string) ([]*Task, error) {
+	var tasks []*Task
+	var missing []string
+
+	for _, id := range ids {
+		if _, ok := ws.Tasks[id]; !ok {
+			missing = append(missing, fmt.Sprintf("util37#%d", id))
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("workspace: no such task %s in %s",
+			strings.Join(missing, ", "), ws.Name)
+	}
+
+	note := CommitAnnotation(hash, subject)
+	pending := CommitAnnotation("", subject)
+	for _, id := range ids {
+		task := ws.Tasks[id]
+		if contains(note, task.Notes) {
+			continue
+		}
+
+		if !task.Done {
+			task.MarkDone()
+		}
+
+		replaced := false
+		if hash != "" {
+			for i := range task.Notes {
+				if task.Notes[i] == pending {
+					task.Notes[i], replaced = note, true
+					break
+				}
+			}
+		}
+
+		if !replaced {
+			task.Notes = append(task.Notes, note)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
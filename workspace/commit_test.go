@@ -0,0 +1,29 @@
+package workspace
+
+import "testing"
+
+func TestCompleteCommit(t *testing.T) {
+	ws := NewWorkspace("test")
+	ws.Tasks[1] = NewTask(1, "Handle time zones")
+
+	if _, err := ws.CompleteCommit([]uint64{1, 2}, "", "Fix it"); err == nil {
+		t.Fatal("completed a task that isn't in the workspace")
+	} else if ws.Tasks[1].Done {
+		t.Fatal("task was completed despite the error")
+	}
+
+	// A commit-msg hook, then a post-commit hook, then the
+	// post-commit hook again.
+	for _, hash := range []string{"", "0123456789abcdef", "0123456789abcdef"} {
+		if _, err := ws.CompleteCommit([]uint64{1}, hash, "Fix it"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	task := ws.Tasks[1]
+	want := []string{"Completed by commit 0123456789ab: Fix it"}
+	if !task.Done || !sameStrings(task.Notes, want) {
+		t.Errorf("task has done %v and notes %q, want notes %q",
+			task.Done, task.Notes, want)
+	}
+}
@@ -31,14 +31,17 @@ func Wrap(s, leading string, max int) string {
 	return string(buf.Bytes())
 }
 
-// Today returns a time.Time for today.
+// Today returns a time.Time for today's local date. Workspace.Today
+// takes the workspace's time zone and day start into account.
 func Today() time.Time {
 	now := time.Now()
 	return time.Date(now.Year(), now.Month(), now.Day(),
 		0, 0, 0, 0, time.Local)
 }
 
-// Day truncates the time value to the day it occurred on.
+// Day truncates the time value to the date it occurred on, which is
+// useful for comparing dates; Workspace.DayOf gives the workspace day
+// a time falls in.
 func Day(t time.Time) time.Time {
 	return time.Date(t.Year(), t.Month(), t.Day(),
 		0, 0, 0, 0, time.Local)
@@ -0,0 +1,45 @@
+package workspace
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Config contains user settings shared by all the tools.
+type Config struct {
+	// Sort is the default sort specification; see ParseSort.
+	Sort string `json:",omitempty"`
+
+	// CSVColumns is the default list of columns for CSV exports;
+	// see ParseCSVColumns.
+	CSVColumns string `json:",omitempty"`
+}
+
+// ConfigFileName returns the name of the configuration file.
+func ConfigFileName() string {
+	basePath := os.Getenv("HOME")
+	return filepath.Join(basePath, ".config", "util37", "config")
+}
+
+// ReadConfig loads the configuration file. A missing file yields the
+// zero Config.
+func ReadConfig() (*Config, error) {
+	var cfg = &Config{}
+
+	in, err := ioutil.ReadFile(ConfigFileName())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(in, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,356 @@
+package workspace
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSV columns.
+const (
+	CSVID       = "id"
+	CSVTitle    = "title"
+	CSVStatus   = "status"
+	CSVPriority = "priority"
+	CSVCreated  = "created"
+	CSVFinished = "finished"
+	CSVDays     = "days"
+	CSVDue      = "due"
+	CSVTags     = "tags"
+	CSVNotes    = "notes"
+)
+
+// DefaultCSVColumns are the columns exported if none are given.
+const DefaultCSVColumns = "id,title,status,priority,created,finished,days,tags,notes"
+
+// csvTimeFormat is the format of times in CSV files, which
+// spreadsheets recognise.
+const csvTimeFormat = "2006-01-02 15:04:05"
+
+// csvAliases maps other common names for columns, as they might
+// appear in a spreadsheet's header, to the columns.
+var csvAliases = map[string]string{
+	CSVID: CSVID, CSVTitle: CSVTitle, CSVStatus: CSVStatus,
+	CSVPriority: CSVPriority, CSVCreated: CSVCreated,
+	CSVFinished: CSVFinished, CSVDays: CSVDays, CSVDue: CSVDue,
+	CSVTags: CSVTags, CSVNotes: CSVNotes,
+
+	"task": CSVTitle, "name": CSVTitle, "summary": CSVTitle,
+	"description": CSVTitle, "state": CSVStatus, "pri": CSVPriority,
+	"entered": CSVCreated, "completed": CSVFinished,
+	"due date": CSVDue, "tag": CSVTags, "categories": CSVTags,
+	"note": CSVNotes, "annotations": CSVNotes,
+}
+
+// ParseCSVColumns parses a comma-separated list of columns. The
+// columns are id, title, status, priority, created, finished, days
+// (the time taken, in days), due, tags and notes.
+func ParseCSVColumns(spec string) ([]string, error) {
+	var cols []string
+	for _, col := range Tokenize(spec, ",") {
+		col = strings.ToLower(col)
+		if csvAliases[col] != col {
+			return nil, errors.New("workspace: unknown CSV column " + col)
+		}
+		cols = append(cols, col)
+	}
+
+	if len(cols) == 0 {
+		return nil, errors.New("workspace: no CSV columns")
+	}
+	return cols, nil
+}
+
+// LoadCSVColumns parses spec or, if it's empty, the CSVColumns
+// setting from the configuration file, falling back to
+// DefaultCSVColumns.
+func LoadCSVColumns(spec string) ([]string, error) {
+	if spec == "" {
+		cfg, err := ReadConfig()
+		if err != nil {
+			return nil, err
+		}
+		spec = cfg.CSVColumns
+	}
+
+	if spec == "" {
+		spec = DefaultCSVColumns
+	}
+	return ParseCSVColumns(spec)
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(csvTimeFormat)
+}
+
+func (t *Task) csvValue(col string) string {
+	switch col {
+	case CSVID:
+		return strconv.FormatUint(t.ID, 10)
+	case CSVTitle:
+		return t.Title
+	case CSVStatus:
+		return t.Record().Status
+	case CSVPriority:
+		return priorityNames[t.Priority]
+	case CSVCreated:
+		return csvTime(t.Created)
+	case CSVFinished:
+		if t.Done {
+			return csvTime(t.Finished)
+		}
+	case CSVDays:
+		return strings.TrimSuffix(t.TimeTaken(), "d")
+	case CSVDue:
+		if !t.Due.IsZero() {
+			return t.Due.Format(DateFormat)
+		}
+	case CSVTags:
+		return strings.Join(t.Tags, ",")
+	case CSVNotes:
+		return strings.Join(t.Notes, "\n")
+	}
+	return ""
+}
+
+// WriteCSV writes the tasks as CSV, with a header row naming the
+// columns.
+func WriteCSV(w io.Writer, tasks []*Task, cols []string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		var row = make([]string, 0, len(cols))
+		for _, col := range cols {
+			row = append(row, t.csvValue(col))
+		}
+
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// parseCSVTime parses a time, which is in the given time zone unless
+// it says otherwise.
+func parseCSVTime(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{csvTimeFormat, "2006-01-02 15:04",
+		time.RFC3339, DateFormat, "01/02/2006"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.In(loc), nil
+		}
+	}
+	return time.Time{}, errors.New("workspace: invalid time " + s)
+}
+
+func csvPriority(s string) (Priority, error) {
+	s = strings.ToLower(s)
+	for pri, name := range priorityNames {
+		if name == s {
+			return pri, nil
+		}
+	}
+
+	if pri := PriorityFromString(strings.ToUpper(s)); pri != PriorityUnknown {
+		return pri, nil
+	}
+	return PriorityUnknown, errors.New("workspace: invalid priority " + s)
+}
+
+// setCSV sets a field of the task from a CSV value, reading times in
+// the workspace's time zone.
+func (t *Task) setCSV(ws *Workspace, col, value string) error {
+	var err error
+	switch col {
+	case CSVID:
+		t.ID, err = strconv.ParseUint(value, 10, 64)
+	case CSVTitle:
+		t.Title = value
+	case CSVStatus:
+		switch strings.ToLower(value) {
+		case "open", "pending", "todo", "no", "false":
+		case "done", "completed", "x", "yes", "true":
+			t.Done = true
+		case "cancelled", "canceled", "deleted":
+			t.Done, t.Cancelled = true, true
+		default:
+			err = errors.New("workspace: invalid status " + value)
+		}
+	case CSVPriority:
+		t.Priority, err = csvPriority(value)
+	case CSVCreated:
+		t.Created, err = parseCSVTime(value, ws.Location())
+	case CSVFinished:
+		t.Finished, err = parseCSVTime(value, ws.Location())
+	case CSVDue:
+		t.Due, err = parseCSVTime(value, ws.Location())
+		t.Due = ws.Date(t.Due)
+	case CSVTags:
+		t.Tags = Tokenize(value, ",")
+	case CSVNotes:
+		t.Notes = normalize(strings.Split(value, "\n"))
+	}
+	return err
+}
+
+// keepCSV copies the fields that weren't read from a CSV file from the
+// existing task old.
+func (t *Task) keepCSV(old *Task, present map[string]bool) {
+	if !present[CSVStatus] && !present[CSVFinished] {
+		t.Done, t.Cancelled, t.Finished = old.Done, old.Cancelled, old.Finished
+	} else if !present[CSVStatus] {
+		t.Cancelled = old.Cancelled && !t.Finished.IsZero()
+	} else if !present[CSVFinished] && t.Done == old.Done {
+		t.Finished = old.Finished
+	}
+
+	if !present[CSVPriority] {
+		t.Priority = old.Priority
+	}
+
+	if !present[CSVCreated] {
+		t.Created = old.Created
+	}
+
+	if !present[CSVDue] {
+		t.Due = old.Due
+	}
+
+	if !present[CSVTags] {
+		t.Tags = old.Tags
+	}
+
+	if !present[CSVNotes] {
+		t.Notes = old.Notes
+	}
+
+	t.Wait, t.UID = old.Wait, old.UID
+}
+
+// ParseCSVMapping parses a list of header=column pairs, such as
+// "Task name=title,Owner=tags", which map a CSV file's headers to
+// columns for ReadCSV.
+func ParseCSVMapping(spec string) (map[string]string, error) {
+	var mapping = map[string]string{}
+	for _, pair := range Tokenize(spec, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("workspace: invalid CSV mapping " + pair)
+		}
+
+		col := strings.ToLower(strings.TrimSpace(kv[1]))
+		if csvAliases[col] != col {
+			return nil, errors.New("workspace: unknown CSV column " + col)
+		}
+		mapping[strings.ToLower(strings.TrimSpace(kv[0]))] = col
+	}
+	return mapping, nil
+}
+
+// ReadCSV reads tasks from a CSV file whose first row is a header.
+// Each header is matched, ignoring case, against the mapping, then the
+// column names and their common aliases (such as "task" or "summary"
+// for the title); the other headers are returned as unmapped. The days
+// column is ignored, as it's worked out from the created and finished
+// times. A title column is required.
+//
+// A row with the ID of a task in the workspace keeps the task's values
+// for any fields the file doesn't have columns for, so a file exported
+// with only some columns can be edited and imported again.
+func (ws *Workspace) ReadCSV(r io.Reader, mapping map[string]string) ([]*Task, Unmapped, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, errors.New("workspace: no CSV header")
+	}
+
+	var cols = make([]string, len(header))
+	var present = map[string]bool{}
+	var hasTitle, hasStatus bool
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if col, ok := mapping[name]; ok {
+			cols[i] = col
+		} else {
+			cols[i] = csvAliases[name]
+		}
+		hasTitle = hasTitle || cols[i] == CSVTitle
+		hasStatus = hasStatus || cols[i] == CSVStatus
+		present[cols[i]] = true
+	}
+
+	if !hasTitle {
+		return nil, nil, errors.New("workspace: the CSV file has no title column")
+	}
+
+	var tasks []*Task
+	var unmapped = Unmapped{}
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		t := &Task{Priority: PriorityNormal}
+		for i, value := range row {
+			value = strings.TrimSpace(value)
+			if i >= len(cols) || value == "" {
+				continue
+			}
+
+			switch cols[i] {
+			case "":
+				unmapped[header[i]]++
+				continue
+			case CSVDays:
+				continue
+			}
+
+			if err = t.setCSV(ws, cols[i], value); err != nil {
+				return nil, nil, errors.New(err.Error() + " (line " + strconv.Itoa(line) + ")")
+			}
+		}
+
+		if t.Title == "" {
+			continue
+		}
+
+		if old := ws.findTask(t); old != nil {
+			t.keepCSV(old, present)
+		}
+
+		if t.Created.IsZero() {
+			t.Created = time.Now()
+		}
+
+		if !hasStatus && !t.Finished.IsZero() {
+			// Without a status, a finish time means the task
+			// was completed.
+			t.Done = true
+		}
+
+		if t.Done && t.Finished.IsZero() {
+			t.Finished = time.Now()
+		} else if !t.Done {
+			t.Finished = time.Time{}
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, unmapped, nil
+}
@@ -0,0 +1,103 @@
+package workspace
+
+import (
+	"errors"
+	"time"
+)
+
+// Location returns the workspace's time zone: the one named by
+// TimeZone, or the local time zone if none is set.
+func (ws *Workspace) Location() *time.Location {
+	if ws.TimeZone == "" {
+		return time.Local
+	}
+
+	if ws.loc == nil || ws.loc.String() != ws.TimeZone {
+		loc, err := time.LoadLocation(ws.TimeZone)
+		if err != nil {
+			return time.Local
+		}
+		ws.loc = loc
+	}
+	return ws.loc
+}
+
+// SetTimeZone sets the workspace's time zone to the named IANA time
+// zone, such as "Europe/London"; an empty name uses the local time
+// zone.
+func (ws *Workspace) SetTimeZone(name string) error {
+	if name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return errors.New("workspace: unknown time zone " + name)
+		}
+		ws.loc = loc
+	}
+
+	ws.TimeZone = name
+	return nil
+}
+
+// SetDayStart sets the hour, from 0 to 23, at which the workspace's
+// day starts.
+func (ws *Workspace) SetDayStart(hour int) error {
+	if hour < 0 || hour > 23 {
+		return errors.New("workspace: the day must start between hours 0 and 23")
+	}
+
+	ws.DayStart = hour
+	return nil
+}
+
+// DayOf returns the workspace day containing t, as midnight of that
+// day in the workspace's time zone. Times before the DayStart hour
+// belong to the previous day.
+func (ws *Workspace) DayOf(t time.Time) time.Time {
+	loc := ws.Location()
+	t = t.In(loc).Add(-time.Duration(ws.DayStart) * time.Hour)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// Date returns midnight of the calendar date of d in the workspace's
+// time zone. Days, such as those returned by DayOf, ParseDay and
+// EntryDay, are represented this way.
+func (ws *Workspace) Date(d time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, ws.Location())
+}
+
+// DayBegin returns the time at which the workspace day d starts.
+func (ws *Workspace) DayBegin(d time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), ws.DayStart, 0, 0, 0, ws.Location())
+}
+
+// DayEnd returns the time at which the workspace day d ends, which
+// is when the next one starts.
+func (ws *Workspace) DayEnd(d time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day()+1, ws.DayStart, 0, 0, 0, ws.Location())
+}
+
+// Today returns the current workspace day.
+func (ws *Workspace) Today() time.Time {
+	return ws.DayOf(time.Now())
+}
+
+// DateID returns the identifier of the entry for the calendar date of
+// d, such as one returned by ParseDay.
+func (ws *Workspace) DateID(d time.Time) uint64 {
+	return uint64(ws.Date(d).Unix())
+}
+
+// EntryDay returns the day of the entry with the given identifier.
+// The identifier is midnight of the day in the time zone in use when
+// the entry was made; noon is used to find the day, so that changing
+// the time zone doesn't move entries to the previous day.
+func (ws *Workspace) EntryDay(id uint64) time.Time {
+	noon := time.Unix(int64(id), 0).Add(12 * time.Hour)
+	return ws.Date(noon.In(ws.Location()))
+}
+
+// EntryID returns the identifier of the entry for the workspace day
+// containing t.
+func (ws *Workspace) EntryID(t time.Time) uint64 {
+	return uint64(ws.DayOf(t).Unix())
+}
@@ -0,0 +1,117 @@
+package workspace
+
+import (
+	"testing"
+	"time"
+)
+
+func testWorkspace(t *testing.T, dayStart int) *Workspace {
+	ws := &Workspace{
+		Tasks:   TaskSet{},
+		Entries: map[uint64]*Entry{},
+	}
+	if err := ws.SetTimeZone("America/New_York"); err != nil {
+		t.Skip("time zone data isn't available")
+	}
+	if err := ws.SetDayStart(dayStart); err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestDayOf(t *testing.T) {
+	ws := testWorkspace(t, 4)
+	loc := ws.Location()
+
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2015, 8, 2, 2, 0, 0, 0, loc), "2015-08-01"},
+		{time.Date(2015, 8, 2, 4, 0, 0, 0, loc), "2015-08-02"},
+		{time.Date(2015, 8, 2, 23, 59, 0, 0, loc), "2015-08-02"},
+		// 03:00 UTC is 23:00 the previous evening in New York.
+		{time.Date(2015, 8, 3, 3, 0, 0, 0, time.UTC), "2015-08-02"},
+	}
+
+	for _, tt := range tests {
+		day := ws.DayOf(tt.at)
+		if got := day.Format(DateFormat); got != tt.want {
+			t.Errorf("DayOf(%v) = %s, want %s", tt.at, got, tt.want)
+		}
+
+		if tt.at.Before(ws.DayBegin(day)) || !tt.at.Before(ws.DayEnd(day)) {
+			t.Errorf("%v isn't between the start and end of %s", tt.at, tt.want)
+		}
+	}
+}
+
+func TestEntryDay(t *testing.T) {
+	ws := testWorkspace(t, 4)
+	day := time.Date(2015, 8, 1, 0, 0, 0, 0, ws.Location())
+	id := ws.DateID(day)
+	if got := ws.EntryDay(id); !got.Equal(day) {
+		t.Errorf("EntryDay(%d) = %v, want %v", id, got, day)
+	}
+
+	// Entries made before the time zone was set keep their day.
+	utc := uint64(time.Date(2015, 8, 1, 0, 0, 0, 0, time.UTC).Unix())
+	if got := ws.EntryDay(utc); !got.Equal(day) {
+		t.Errorf("EntryDay(%d) = %v, want %v", utc, got, day)
+	}
+}
+
+func TestCarryUsesDayStart(t *testing.T) {
+	ws := testWorkspace(t, 4)
+	loc := ws.Location()
+	day := time.Date(2015, 8, 2, 0, 0, 0, 0, loc)
+
+	late := NewTask(1, "finished after midnight")
+	late.Done = true
+	late.Finished = time.Date(2015, 8, 2, 2, 0, 0, 0, loc)
+
+	early := NewTask(2, "finished the day before")
+	early.Done = true
+	early.Finished = time.Date(2015, 8, 1, 12, 0, 0, 0, loc)
+
+	open := NewTask(3, "unfinished")
+	waiting := NewTask(4, "waiting")
+	waiting.Wait = time.Date(2015, 8, 3, 0, 0, 0, 0, loc)
+
+	for _, task := range []*Task{late, early, open, waiting} {
+		ws.Tasks[task.ID] = task
+	}
+
+	got := ws.carry(&Entry{Tasks: []uint64{1, 2, 3, 4}}, day)
+	if len(got) != 1 || got[0] != 3 {
+		t.Errorf("carry = %v, want [3]", got)
+	}
+}
+
+func TestBackfillKeepsEntries(t *testing.T) {
+	ws := testWorkspace(t, 4)
+	loc := ws.Location()
+	first := time.Date(2015, 8, 1, 0, 0, 0, 0, loc)
+	today := time.Date(2015, 8, 4, 0, 0, 0, 0, loc)
+
+	// An entry made before the day start was set, dated at 02:00.
+	ws.Entries[ws.DateID(first)] = &Entry{
+		Date:  first.Add(2 * time.Hour),
+		Tasks: []uint64{1},
+	}
+	second := &Entry{Date: first.AddDate(0, 0, 1)}
+	ws.Entries[ws.DateID(second.Date)] = second
+
+	last := ws.backfill(ws.DateID(first), today)
+	if len(ws.Entries) != 3 {
+		t.Fatalf("have %d entries, want 3", len(ws.Entries))
+	}
+
+	if ws.Entries[ws.DateID(second.Date)] != second {
+		t.Error("backfill replaced an existing entry")
+	}
+
+	if !last.Backfilled || !last.Date.Equal(today.AddDate(0, 0, -1)) {
+		t.Errorf("last entry is %+v, want a backfilled entry for 2015-08-03", last)
+	}
+}
@@ -11,9 +11,14 @@ import (
 type Filter func(TaskSet) TaskSet
 type FilterChain struct {
 	chain  []Filter
+	words  []string
 	start  time.Time
 	end    time.Time
 	status CompletionStatus
+
+	// ws is the workspace the query is run against, if any; some
+	// filters need more than the tasks themselves.
+	ws *Workspace
 }
 
 func (c FilterChain) Filter(ts TaskSet) TaskSet {
@@ -27,7 +32,7 @@ func (c FilterChain) Filter(ts TaskSet) TaskSet {
 func CompletedFilter(ts TaskSet) TaskSet {
 	var tasks = TaskSet{}
 	for id, task := range ts {
-		if task.Done {
+		if task.Done && !task.Cancelled {
 			tasks[id] = task
 		}
 	}
@@ -160,6 +165,8 @@ var (
 	unmatchedRegexp = regexp.MustCompile(`^\w+:.*$`)
 	uncasedRegexp   = regexp.MustCompile(`^i:.+$`)
 	explicitRegexp  = regexp.MustCompile(`^r:.+$`)
+	staleRegexp     = regexp.MustCompile(`^stale:(>=?)?(\d+)$`)
+	waitingRegexp   = regexp.MustCompile(`^waiting:(.*)$`)
 )
 
 func DurationFilter(durs string) (Filter, time.Time, error) {
@@ -251,6 +258,30 @@ func (c *FilterChain) processQueryWord(word string) (err error) {
 	case explicitRegexp.MatchString(word):
 		query := word[2:]
 		f, err = TitleFilter(query)
+	case staleRegexp.MatchString(word):
+		if c.ws == nil {
+			return errors.New("workspace: " + word + " needs a workspace")
+		}
+
+		subs := staleRegexp.FindStringSubmatch(word)
+		var n int
+		n, err = strconv.Atoi(subs[2])
+		f = c.ws.StaleFilter(n, subs[1] != ">")
+	case waitingRegexp.MatchString(word):
+		subs := waitingRegexp.FindStringSubmatch(word)
+		ws := c.ws
+		if ws == nil {
+			ws = &Workspace{}
+		}
+
+		if subs[1] == "" {
+			f = WaitingFilter(ws.Today())
+		} else {
+			date, err = ws.ParseWait(subs[1])
+			f = WaitingUntilFilter(ws.Today(), date)
+		}
+	case queryRefRegexp.MatchString(word):
+		err = errors.New("workspace: unexpanded query " + word)
 	case unmatchedRegexp.MatchString(word):
 		err = errors.New("workspace: unmatched tag " + word)
 	default:
@@ -274,7 +305,11 @@ const (
 )
 
 func ProcessQuery(args []string, status CompletionStatus) (*FilterChain, error) {
-	var c = &FilterChain{status: status}
+	return processQuery(args, status, nil)
+}
+
+func processQuery(args []string, status CompletionStatus, ws *Workspace) (*FilterChain, error) {
+	var c = &FilterChain{status: status, ws: ws}
 
 	switch status {
 	case StatusCompleted:
@@ -293,6 +328,7 @@ func ProcessQuery(args []string, status CompletionStatus) (*FilterChain, error)
 		if err != nil {
 			return nil, err
 		}
+		c.words = append(c.words, word)
 	}
 
 	return c, nil
@@ -310,6 +346,41 @@ func (c *FilterChain) TimeRange() string {
 	}
 }
 
+// SplitRange separates the words of a query that select a time range
+// (from:, to: and last:) from the rest of the query. This is useful
+// when the range should apply to a report rather than select tasks by
+// their completion date.
+func SplitRange(words []string) (dates, rest []string) {
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		switch {
+		case fromRegexp.MatchString(word), toRegexp.MatchString(word),
+			lastRegexp.MatchString(word):
+			dates = append(dates, word)
+		default:
+			rest = append(rest, word)
+		}
+	}
+	return dates, rest
+}
+
+// Query returns the words of the query that built the chain.
+func (c *FilterChain) Query() []string {
+	return c.words
+}
+
+// Start returns the start of the time range selected by the query; it
+// is the zero time if the query has no lower bound.
+func (c *FilterChain) Start() time.Time {
+	return c.start
+}
+
+// End returns the end of the time range selected by the query; it is
+// the zero time if the query has no upper bound.
+func (c *FilterChain) End() time.Time {
+	return c.end
+}
+
 func (c *FilterChain) Len() int {
 	return len(c.chain)
 }
@@ -318,7 +389,9 @@ var FilterUsage = `Filter language:
 
 Filters can be used in many places to limit the scope of the active tasks.
 
-    t:<tag> or tag:<tag>	Only show tasks with the <tag>
+    t:<tag> or tag:<tag>	Only show tasks with the <tag>, or any tag
+				beneath it in the hierarchy, e.g. t:client
+				matches client/acme.
     i:<regex>			Case insensitive regexp; shorthand for
 				'(?i:regex)'.
     from:YYYY-MM-DD		Only show tasks after the date given
@@ -337,6 +410,15 @@ Filters can be used in many places to limit the scope of the active tasks.
     r:<regexp>			Explicitly pass in a regular expression; this
     				is useful for queries that might otherwise be
 				parsed as a tag.
+    stale:<n>			Only show unfinished tasks that have been
+				carried over from one day's list to the next
+				at least n times; stale:>n selects those
+				carried over more than n times.
+    waiting:			Only show tasks that have been snoozed or
+				scheduled to start on a later day; with a
+				day, such as waiting:1w, only those that will
+				start by then.
+    @<name>			Expand a saved query; see util37-query.
 
 Any non-tag words are used as a regular expression to select tasks by title.
 `
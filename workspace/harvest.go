@@ -0,0 +1,282 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A Comment is a TODO, FIXME or XXX comment found in a source file.
+type Comment struct {
+	Path string // relative to the directory scanned, with slashes
+	Line int
+	Kind string
+	Text string
+
+	// Fingerprint identifies the comment independently of its line,
+	// so that it can be found again after the file has changed.
+	Fingerprint string
+}
+
+// Location returns the comment's file and line, as path:line.
+func (c *Comment) Location() string {
+	return fmt.Sprintf("%s:%d", c.Path, c.Line)
+}
+
+// commentRegexp matches a comment from its marker to the end of the
+// line; matchComment finds where the comment can start.
+var commentRegexp = regexp.MustCompile(`^(?://|#|/\*|\*|--|;|<!--)\s*(TODO|FIXME|XXX)\b(?:\([^)]*\))?:?\s*(.*?)\s*(?:\*/|-->)?\s*$`)
+
+// matchComment returns the submatches of commentRegexp for the comment
+// in a line, if there is one. A comment marker must be at the start of
+// the line or follow whitespace, and must not be inside a string, so
+// that "# TODO" or x--; aren't read as comments. A * marker, from the
+// middle of a block comment, must start the line.
+func matchComment(line string) []string {
+	if !strings.Contains(line, "TODO") && !strings.Contains(line, "FIXME") &&
+		!strings.Contains(line, "XXX") {
+		return nil
+	}
+
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if quote != 0 {
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '"' || c == '`':
+			quote = c
+			continue
+		case c == '\'' && (i == 0 || !isWordByte(line[i-1])):
+			// An apostrophe within a word doesn't start a string.
+			quote = c
+			continue
+		case i > 0 && line[i-1] != ' ' && line[i-1] != '\t':
+			continue
+		case c == '*' && strings.TrimSpace(line[:i]) != "":
+			continue
+		}
+
+		if subs := commentRegexp.FindStringSubmatch(line[i:]); subs != nil {
+			return subs
+		}
+	}
+	return nil
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// skipDirs are directories that aren't scanned for comments, along
+// with those whose names start with a dot.
+var skipDirs = map[string]bool{
+	"vendor":       true,
+	"node_modules": true,
+	"testdata":     true,
+}
+
+const maxScanSize = 1 << 20
+
+// ScanComments returns the comments in the files under dir, skipping
+// hidden and vendored directories and binary files.
+func ScanComments(dir string) ([]*Comment, error) {
+	var comments []*Comment
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := fi.Name()
+		if fi.IsDir() {
+			if path != dir && (strings.HasPrefix(name, ".") || skipDirs[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !fi.Mode().IsRegular() || fi.Size() > maxScanSize {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		found, err := scanFile(path, filepath.ToSlash(rel))
+		comments = append(comments, found...)
+		return err
+	})
+
+	return comments, err
+}
+
+func scanFile(path, rel string) ([]*Comment, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+
+	return readComments(bytes.NewReader(data), rel)
+}
+
+func readComments(r io.Reader, rel string) ([]*Comment, error) {
+	var comments []*Comment
+	var seen = map[string]int{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxScanSize)
+	for n := 1; scanner.Scan(); n++ {
+		subs := matchComment(scanner.Text())
+		if subs == nil {
+			continue
+		}
+
+		c := &Comment{
+			Path: rel,
+			Line: n,
+			Kind: subs[1],
+			Text: strings.Join(strings.Fields(subs[2]), " "),
+		}
+
+		// Identical comments in a file are told apart by the
+		// order they appear in.
+		key := c.Kind + "\x00" + c.Text
+		h := sha1.Sum([]byte(fmt.Sprintf("%s\x00%s\x00%d", rel, key, seen[key])))
+		seen[key]++
+
+		c.Fingerprint = fmt.Sprintf("%x", h[:6])
+		comments = append(comments, c)
+	}
+
+	return comments, scanner.Err()
+}
+
+// HarvestUID returns the UID of the task for a comment in the named
+// project.
+func HarvestUID(project string, c *Comment) string {
+	return harvestPrefix + project + "/" + c.Fingerprint
+}
+
+const harvestPrefix = "harvest:"
+
+// harvestProject returns the project named in a UID returned by
+// HarvestUID, or false if it isn't one. Project names may contain
+// slashes, but fingerprints don't.
+func harvestProject(uid string) (string, bool) {
+	i := strings.LastIndex(uid, "/")
+	if !strings.HasPrefix(uid, harvestPrefix) || i < len(harvestPrefix) {
+		return "", false
+	}
+	return uid[len(harvestPrefix):i], true
+}
+
+const locationPrefix = "Found at "
+
+// A HarvestResult lists the tasks changed by a harvest.
+type HarvestResult struct {
+	*ImportResult
+	Completed []*Task
+}
+
+// harvestTask returns the task for a comment, based on the existing
+// task if there is one.
+func (ws *Workspace) harvestTask(project string, c *Comment) *Task {
+	uid := HarvestUID(project, c)
+	title := c.Text
+	if title == "" {
+		title = c.Kind + " in " + c.Path
+	}
+
+	tags := []string{
+		strings.ToLower(c.Kind),
+		strings.TrimSuffix(project+"/"+c.Path, "/"),
+	}
+	location := locationPrefix + c.Location()
+
+	for _, old := range ws.Tasks {
+		if old.UID != uid {
+			continue
+		}
+
+		t := *old
+		t.Title = title
+		t.Tags = append([]string{}, old.Tags...)
+		for _, tag := range tags {
+			if !contains(tag, t.Tags) {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+
+		t.Notes = []string{location}
+		for _, note := range old.Notes {
+			if !strings.HasPrefix(note, locationPrefix) {
+				t.Notes = append(t.Notes, note)
+			}
+		}
+		return &t
+	}
+
+	t := NewTask(0, title)
+	if c.Kind != "TODO" {
+		t.Priority = PriorityHigh
+	}
+	t.UID, t.Tags, t.Notes = uid, tags, []string{location}
+	return t
+}
+
+// Harvest creates or updates a task for each comment found in the
+// named project, and completes the project's tasks whose comments
+// are no longer found. Tasks that have been completed aren't reopened.
+func (ws *Workspace) Harvest(project string, comments []*Comment) *HarvestResult {
+	var tasks []*Task
+	var uids = map[string]bool{}
+
+	for _, c := range comments {
+		uid := HarvestUID(project, c)
+		if uids[uid] {
+			continue
+		}
+		uids[uid] = true
+		tasks = append(tasks, ws.harvestTask(project, c))
+	}
+
+	r := &HarvestResult{}
+	for _, t := range ws.Tasks {
+		if t.Done || uids[t.UID] {
+			continue
+		}
+
+		if name, ok := harvestProject(t.UID); ok && name == project {
+			t.MarkDone()
+			r.Completed = append(r.Completed, t)
+		}
+	}
+
+	r.ImportResult = ws.Import(tasks, nil)
+	return r
+}
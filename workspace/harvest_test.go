@@ -0,0 +1,115 @@
+package workspace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchComment(t *testing.T) {
+	tests := []struct {
+		line string
+		kind string
+		text string
+	}{
+		{"// TODO: handle time zones", "TODO", "handle time zones"},
+		{"\tx := 1 // FIXME(alice) overflows", "FIXME", "overflows"},
+		{"# XXX remove this", "XXX", "remove this"},
+		{"x = 1  # TODO tidy up", "TODO", "tidy up"},
+		{"/* TODO: one line */", "TODO", "one line"},
+		{" * TODO inside a block comment", "TODO", "inside a block comment"},
+		{"-- TODO index this column", "TODO", "index this column"},
+		{"(setq x 1) ; TODO use let", "TODO", "use let"},
+		{"<!-- TODO: fix the layout -->", "TODO", "fix the layout"},
+		{"x--; // TODO: check the bounds", "TODO", "check the bounds"},
+		{`s := "# TODO" // TODO: real one`, "TODO", "real one"},
+		{`s := "it's \" // TODO"`, "", ""},
+		{`fmt.Println("# TODO")`, "", ""},
+		{"s = '-- TODO'", "", ""},
+		{"x := `; TODO`", "", ""},
+		{"a * TODO", "", ""},
+		{"x--TODO", "", ""},
+		{"url := \"http://example.com\" // XXX: hard-coded", "XXX", "hard-coded"},
+		{"// TODOS aren't comments", "", ""},
+		{"no comment here", "", ""},
+	}
+
+	for _, test := range tests {
+		subs := matchComment(test.line)
+		if test.kind == "" {
+			if subs != nil {
+				t.Errorf("%q matched as %q", test.line, subs[0])
+			}
+			continue
+		}
+
+		if subs == nil {
+			t.Errorf("%q didn't match", test.line)
+		} else if subs[1] != test.kind || subs[2] != test.text {
+			t.Errorf("%q matched as %s %q, want %s %q",
+				test.line, subs[1], subs[2], test.kind, test.text)
+		}
+	}
+}
+
+func TestFingerprintStable(t *testing.T) {
+	before := "package main\n\n// TODO: first\nfunc main() {}\n\n// TODO: first\n"
+	after := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\t// TODO: first\n\tfmt.Println()\n}\n\n// TODO: first\n"
+
+	old, err := readComments(strings.NewReader(before), "main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := readComments(strings.NewReader(after), "main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(old) != 2 || len(moved) != 2 {
+		t.Fatalf("found %d and %d comments, want 2", len(old), len(moved))
+	}
+
+	for i := range old {
+		if old[i].Line == moved[i].Line {
+			t.Errorf("comment %d didn't move", i)
+		}
+
+		if old[i].Fingerprint != moved[i].Fingerprint {
+			t.Errorf("comment %d fingerprint changed from %s to %s",
+				i, old[i].Fingerprint, moved[i].Fingerprint)
+		}
+	}
+
+	if old[0].Fingerprint == old[1].Fingerprint {
+		t.Error("identical comments have the same fingerprint")
+	}
+
+	other, err := readComments(strings.NewReader(before), "other.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if other[0].Fingerprint == old[0].Fingerprint {
+		t.Error("comments in different files have the same fingerprint")
+	}
+}
+
+func TestHarvestProjects(t *testing.T) {
+	ws := testWorkspace(t, 0)
+	comments, err := readComments(strings.NewReader("// TODO: first\n"), "main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := ws.Harvest("foo/bar", comments); len(r.Added) != 1 {
+		t.Fatalf("added %d tasks, want 1", len(r.Added))
+	}
+
+	if r := ws.Harvest("foo", nil); len(r.Completed) != 0 {
+		t.Errorf("project foo completed %d of foo/bar's tasks", len(r.Completed))
+	}
+
+	if r := ws.Harvest("foo/bar", nil); len(r.Completed) != 1 {
+		t.Errorf("project foo/bar completed %d tasks, want 1", len(r.Completed))
+	}
+}
@@ -0,0 +1,212 @@
+package workspace
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DayCounts summarises the state of a set of tasks at the end of a
+// day.
+type DayCounts struct {
+	Date time.Time
+
+	// Open is the number of tasks that were open at the end of
+	// the day.
+	Open int
+
+	// Done is the number of tasks completed on or before the day.
+	Done int
+
+	// Completed is the number of tasks completed during the day.
+	Completed int
+}
+
+func openAt(task *Task, end time.Time) bool {
+	return task.Created.Before(end) &&
+		(!task.Done || !task.Finished.Before(end))
+}
+
+// History returns the daily counts for the tasks in ts from start to
+// end inclusive. Where the workspace has an entry for a day, the open
+// count is taken from the tasks on that day's list; otherwise, it's
+// worked out from when each task was created and finished.
+func (ws *Workspace) History(ts TaskSet, start, end time.Time) []DayCounts {
+	var days []DayCounts
+
+	for d := ws.Date(start); !d.After(ws.Date(end)); d = d.AddDate(0, 0, 1) {
+		next := ws.DayEnd(d)
+		counts := DayCounts{Date: d}
+
+		for _, task := range ts {
+			if task.Done && task.Finished.Before(next) {
+				counts.Done++
+				if !task.Finished.Before(ws.DayBegin(d)) {
+					counts.Completed++
+				}
+			}
+		}
+
+		if e, ok := ws.Entries[ws.DateID(d)]; ok {
+			for _, id := range e.Tasks {
+				task, ok := ts[id]
+				if ok && openAt(task, next) {
+					counts.Open++
+				}
+			}
+		} else {
+			for _, task := range ts {
+				if openAt(task, next) {
+					counts.Open++
+				}
+			}
+		}
+
+		days = append(days, counts)
+	}
+
+	return days
+}
+
+// ParseDay parses a day given as YYYY-MM-DD, "today", "yesterday", or
+// a negative number of days relative to today, such as "-3". Today is
+// the workspace's current day, which takes its time zone and day
+// start into account.
+func (ws *Workspace) ParseDay(s string) (time.Time, error) {
+	switch s {
+	case "today":
+		return ws.Today(), nil
+	case "yesterday":
+		return ws.Today().AddDate(0, 0, -1), nil
+	}
+
+	if strings.HasPrefix(s, "-") {
+		n, err := strconv.Atoi(s[1:])
+		if err != nil {
+			return time.Time{}, errors.New("workspace: invalid day " + s)
+		}
+		return ws.Today().AddDate(0, 0, -n), nil
+	}
+
+	t, err := time.ParseInLocation(DateFormat, s, ws.Location())
+	if err != nil {
+		return time.Time{}, errors.New("workspace: invalid day " + s)
+	}
+	return t, nil
+}
+
+// EntryIDs returns the identifiers of the workspace's entries in
+// chronological order.
+func (ws *Workspace) EntryIDs() []uint64 {
+	var ids = make([]uint64, 0, len(ws.Entries))
+	for id := range ws.Entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// TaskEntries returns the identifiers of the entries that list the
+// task, in chronological order.
+func (ws *Workspace) TaskEntries(id uint64) []uint64 {
+	var ids []uint64
+	for _, eid := range ws.EntryIDs() {
+		if ws.Entries[eid].Index(id) >= 0 {
+			ids = append(ids, eid)
+		}
+	}
+	return ids
+}
+
+// AdjacentEntry returns the entry before (if dir is negative) or after
+// the given entry, and false if there isn't one.
+func (ws *Workspace) AdjacentEntry(id uint64, dir int) (uint64, bool) {
+	ids := ws.EntryIDs()
+	if dir < 0 {
+		for i := len(ids) - 1; i >= 0; i-- {
+			if ids[i] < id {
+				return ids[i], true
+			}
+		}
+	} else {
+		for i := range ids {
+			if ids[i] > id {
+				return ids[i], true
+			}
+		}
+	}
+	return 0, false
+}
+
+// AsOf returns a copy of the task as it stood at the given time: if
+// it was finished later, the copy is unfinished.
+func (t *Task) AsOf(at time.Time) *Task {
+	task := *t
+	if task.Done && !task.Finished.Before(at) {
+		task.Done = false
+		task.Finished = time.Time{}
+	}
+	return &task
+}
+
+// An EntryDiff describes how the list of tasks changed between two
+// entries.
+type EntryDiff struct {
+	// Added contains the tasks on the later list but not the
+	// earlier one, other than those in Completed.
+	Added []*Task
+
+	// Completed contains the tasks on either list that were
+	// completed from the day of the earlier entry up to the end of
+	// the day of the later one.
+	Completed []*Task
+
+	// Carried contains the tasks on both lists, other than those in
+	// Completed.
+	Carried []*Task
+
+	// Dropped contains the unfinished tasks on the earlier list that
+	// aren't on the later one.
+	Dropped []*Task
+}
+
+// DiffEntries compares two entries; from should be the earlier of the
+// two.
+func (ws *Workspace) DiffEntries(from, to uint64) *EntryDiff {
+	a := ws.EntryTasks(from)
+	b := ws.EntryTasks(to)
+	start := ws.DayBegin(ws.EntryDay(from))
+	end := ws.DayEnd(ws.EntryDay(to))
+
+	completed := func(task *Task) bool {
+		return task.Done && !task.Finished.Before(start) &&
+			task.Finished.Before(end)
+	}
+
+	d := &EntryDiff{}
+	for _, task := range b.Sort() {
+		if completed(task) {
+			d.Completed = append(d.Completed, task)
+		} else if _, ok := a[task.ID]; ok {
+			d.Carried = append(d.Carried, task)
+		} else {
+			d.Added = append(d.Added, task)
+		}
+	}
+
+	for _, task := range a.Sort() {
+		if _, ok := b[task.ID]; ok {
+			continue
+		}
+
+		if completed(task) {
+			d.Completed = append(d.Completed, task)
+		} else if !task.AsOf(end).Done {
+			d.Dropped = append(d.Dropped, task)
+		}
+	}
+
+	return d
+}
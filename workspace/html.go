@@ -0,0 +1,197 @@
+package workspace
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+)
+
+// Ways of grouping the tasks in an HTML report.
+const (
+	GroupNone     = "none"
+	GroupTag      = "tag"
+	GroupPriority = "priority"
+)
+
+// An HTMLGroup is a titled group of tasks in an HTML report.
+type HTMLGroup struct {
+	Name  string
+	Tasks []*Task
+}
+
+// An HTMLReport is a self-contained HTML page listing tasks.
+type HTMLReport struct {
+	Title     string
+	Workspace string
+	Range     string
+	Generated time.Time
+
+	// Count, Open and Done summarise the tasks in the report.
+	Count, Open, Done int
+
+	// Priorities counts the tasks at each priority, highest first.
+	Priorities []HTMLGroup
+
+	// Groups contains the tasks; a task with several tags appears
+	// in each of their groups when grouping by tag.
+	Groups []HTMLGroup
+}
+
+func groupByPriority(tasks []*Task) []HTMLGroup {
+	var groups []HTMLGroup
+	for _, pri := range priorityOrder {
+		var group = HTMLGroup{Name: priorityNames[pri]}
+		for _, task := range tasks {
+			if task.Priority == pri {
+				group.Tasks = append(group.Tasks, task)
+			}
+		}
+
+		if len(group.Tasks) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func groupByTag(tasks []*Task) []HTMLGroup {
+	var byTag = map[string][]*Task{}
+	var untagged []*Task
+	for _, task := range tasks {
+		if len(task.Tags) == 0 {
+			untagged = append(untagged, task)
+		}
+		for _, tag := range task.Tags {
+			byTag[tag] = append(byTag[tag], task)
+		}
+	}
+
+	var tags = make([]string, 0, len(byTag))
+	for tag := range byTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	var groups []HTMLGroup
+	for _, tag := range tags {
+		groups = append(groups, HTMLGroup{Name: tag, Tasks: byTag[tag]})
+	}
+
+	if len(untagged) > 0 {
+		groups = append(groups, HTMLGroup{Name: "untagged", Tasks: untagged})
+	}
+	return groups
+}
+
+// NewHTMLReport builds an HTML report from the tasks, which should
+// already be sorted; group is one of GroupNone, GroupTag or
+// GroupPriority.
+func NewHTMLReport(title string, ws *Workspace, c *FilterChain, tasks []*Task, group string) (*HTMLReport, error) {
+	r := &HTMLReport{
+		Title:      title,
+		Workspace:  ws.Name,
+		Range:      c.TimeRange(),
+		Generated:  time.Now(),
+		Count:      len(tasks),
+		Priorities: groupByPriority(tasks),
+	}
+
+	for _, task := range tasks {
+		if task.Done {
+			r.Done++
+		} else {
+			r.Open++
+		}
+	}
+
+	switch group {
+	case GroupNone, "":
+		r.Groups = []HTMLGroup{{Tasks: tasks}}
+	case GroupTag:
+		r.Groups = groupByTag(tasks)
+	case GroupPriority:
+		r.Groups = r.Priorities
+	default:
+		return nil, errors.New("workspace: unknown grouping " + group)
+	}
+
+	return r, nil
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(DateFormat)
+	},
+	"priority": func(pri Priority) string {
+		return priorityNames[pri]
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif;
+       color: #222; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+h1 { font-size: 1.5em; margin-bottom: 0.2em; }
+h2 { font-size: 1.15em; margin-top: 1.5em; border-bottom: 1px solid #ddd; }
+.meta { color: #666; margin-top: 0; }
+.summary { border-collapse: collapse; margin: 1em 0; }
+.summary td { padding: 0.2em 1em 0.2em 0; }
+.summary td.n { font-weight: bold; text-align: right; }
+table.tasks { border-collapse: collapse; width: 100%; }
+table.tasks th { text-align: left; background: #f4f4f4; }
+table.tasks th, table.tasks td { padding: 0.3em 0.5em; border-bottom: 1px solid #eee;
+                                 vertical-align: top; }
+td.status { width: 1.5em; text-align: center; }
+td.date, td.taken { white-space: nowrap; }
+.pri { font-size: 0.85em; padding: 0 0.4em; border-radius: 0.3em; }
+.pri-urgent { background: #fdd; color: #900; }
+.pri-high { background: #fed; color: #a50; }
+.pri-normal { background: #eef; color: #336; }
+.pri-low, .pri-unknown { background: #eee; color: #666; }
+.tag { font-size: 0.85em; color: #555; background: #f0f0f0;
+       padding: 0 0.3em; margin-right: 0.3em; }
+details { margin-top: 0.3em; color: #444; }
+summary { cursor: pointer; color: #666; font-size: 0.9em; }
+details p { margin: 0.3em 0 0.3em 1em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Workspace {{.Workspace}}{{if .Range}}, {{.Range}}{{end}};
+generated {{.Generated.Format "2006-01-02 15:04"}}</p>
+<table class="summary">
+<tr><td>Tasks</td><td class="n">{{.Count}}</td></tr>
+<tr><td>Completed</td><td class="n">{{.Done}}</td></tr>
+<tr><td>Open</td><td class="n">{{.Open}}</td></tr>
+{{range .Priorities}}<tr><td><span class="pri pri-{{.Name}}">{{.Name}}</span></td><td class="n">{{len .Tasks}}</td></tr>
+{{end}}</table>
+{{range .Groups}}{{if .Name}}<h2>{{.Name}} ({{len .Tasks}})</h2>
+{{end}}<table class="tasks">
+<tr><th></th><th>Task</th><th>Priority</th><th>Created</th><th>Completed</th><th>Time taken</th></tr>
+{{range .Tasks}}<tr>
+<td class="status">{{if .Cancelled}}&#8211;{{else if .Done}}&#10003;{{end}}</td>
+<td>{{.Title}}{{if .Tags}}<br>{{range .Tags}}<span class="tag">{{.}}</span>{{end}}{{end}}{{if .Notes}}
+<details><summary>Notes ({{len .Notes}})</summary>{{range .Notes}}<p>{{.}}</p>{{end}}</details>{{end}}</td>
+<td><span class="pri pri-{{priority .Priority}}">{{priority .Priority}}</span></td>
+<td class="date">{{date .Created}}</td>
+<td class="date">{{if .Done}}{{date .Finished}}{{end}}</td>
+<td class="taken">{{.TimeTaken}}</td>
+</tr>
+{{else}}<tr><td></td><td colspan="5">No tasks found.</td></tr>
+{{end}}</table>
+{{else}}<p>No tasks found.</p>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes the report as a single HTML page with embedded CSS.
+func (r *HTMLReport) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
@@ -0,0 +1,374 @@
+package workspace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	icalDateFormat     = "20060102"
+	icalDateTimeFormat = "20060102T150405"
+	icalUIDSuffix      = "@util37"
+)
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// icalLine writes a content line, folding it at 75 octets as RFC 5545
+// requires.
+func icalLine(w *bufio.Writer, line string) {
+	for len(line) > 75 {
+		n := 75
+		for n > 0 && line[n]&0xc0 == 0x80 {
+			n-- // Don't split a UTF-8 sequence.
+		}
+		w.WriteString(line[:n] + "\r\n")
+		line = " " + line[n:]
+	}
+	w.WriteString(line + "\r\n")
+}
+
+// icalUID returns the task's UID, or one derived from its ID.
+func icalUID(t *Task) string {
+	if t.UID != "" {
+		return t.UID
+	}
+	return fmt.Sprintf("%d%s", t.ID, icalUIDSuffix)
+}
+
+func icalPriority(pri Priority) int {
+	switch pri {
+	case PriorityUrgent:
+		return 1
+	case PriorityHigh:
+		return 3
+	case PriorityNormal:
+		return 5
+	case PriorityLow:
+		return 9
+	}
+	return 0
+}
+
+// WriteICal writes the tasks as an iCalendar file containing a VTODO
+// for each task.
+func WriteICal(w io.Writer, tasks []*Task) error {
+	bw := bufio.NewWriter(w)
+	stamp := time.Now().UTC().Format(icalDateTimeFormat) + "Z"
+	utc := func(t time.Time) string {
+		return t.UTC().Format(icalDateTimeFormat) + "Z"
+	}
+
+	icalLine(bw, "BEGIN:VCALENDAR")
+	icalLine(bw, "VERSION:2.0")
+	icalLine(bw, "PRODID:-//kisom//utility37//EN")
+	for _, t := range tasks {
+		icalLine(bw, "BEGIN:VTODO")
+		icalLine(bw, "UID:"+icalEscaper.Replace(icalUID(t)))
+		icalLine(bw, "DTSTAMP:"+stamp)
+		icalLine(bw, "SUMMARY:"+icalEscaper.Replace(t.Title))
+		icalLine(bw, "CREATED:"+utc(t.Created))
+
+		switch {
+		case t.Cancelled:
+			icalLine(bw, "STATUS:CANCELLED")
+			icalLine(bw, "LAST-MODIFIED:"+utc(t.Finished))
+		case t.Done:
+			icalLine(bw, "STATUS:COMPLETED")
+			icalLine(bw, "COMPLETED:"+utc(t.Finished))
+		default:
+			icalLine(bw, "STATUS:NEEDS-ACTION")
+		}
+
+		if pri := icalPriority(t.Priority); pri != 0 {
+			icalLine(bw, "PRIORITY:"+strconv.Itoa(pri))
+		}
+
+		if !t.Due.IsZero() {
+			icalLine(bw, "DUE;VALUE=DATE:"+t.Due.Format(icalDateFormat))
+		}
+
+		if !t.Wait.IsZero() {
+			icalLine(bw, "DTSTART;VALUE=DATE:"+t.Wait.Format(icalDateFormat))
+		}
+
+		if len(t.Tags) > 0 {
+			var tags = make([]string, 0, len(t.Tags))
+			for _, tag := range t.Tags {
+				tags = append(tags, icalEscaper.Replace(tag))
+			}
+			icalLine(bw, "CATEGORIES:"+strings.Join(tags, ","))
+		}
+
+		if len(t.Notes) > 0 {
+			icalLine(bw, "DESCRIPTION:"+icalEscaper.Replace(strings.Join(t.Notes, "\n")))
+		}
+
+		icalLine(bw, fmt.Sprintf("X-UTIL37-ID:%d", t.ID))
+		icalLine(bw, "END:VTODO")
+	}
+	icalLine(bw, "END:VCALENDAR")
+
+	return bw.Flush()
+}
+
+// An icalProperty is a content line: NAME;PARAM=VALUE:value.
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+func parseICalLine(line string) (icalProperty, error) {
+	var p = icalProperty{Params: map[string]string{}}
+
+	// The value starts at the first colon outside a quoted
+	// parameter value.
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon == -1; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+
+	if colon == -1 {
+		return p, errors.New("workspace: invalid iCalendar line " + line)
+	}
+
+	p.Value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	p.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			p.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return p, nil
+}
+
+// icalText unescapes a text value.
+func icalText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// icalList splits a list of text values on unescaped commas.
+func icalList(s string) []string {
+	var values []string
+	var start int
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+		} else if s[i] == ',' {
+			values = append(values, icalText(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, icalText(s[start:]))
+}
+
+// time parses the property's date or time. Dates and floating times
+// are in the given time zone, and other times are converted to it.
+func (p icalProperty) time(loc *time.Location) (time.Time, error) {
+	if p.Params["VALUE"] == "DATE" || len(p.Value) == len(icalDateFormat) {
+		return time.ParseInLocation(icalDateFormat, p.Value, loc)
+	}
+
+	if strings.HasSuffix(p.Value, "Z") {
+		t, err := time.Parse(icalDateTimeFormat+"Z", p.Value)
+		return t.In(loc), err
+	}
+
+	zone := loc
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			zone = l
+		}
+	}
+
+	t, err := time.ParseInLocation(icalDateTimeFormat, p.Value, zone)
+	return t.In(loc), err
+}
+
+// ReadICal reads the VTODOs in an iCalendar file, in the workspace's
+// time zone, returning the properties and components that couldn't be
+// converted. Each task's
+// UID is kept, so importing the file again updates the same tasks.
+func (ws *Workspace) ReadICal(r io.Reader) ([]*Task, Unmapped, error) {
+	lines, err := unfoldICal(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tasks []*Task
+	var unmapped = Unmapped{}
+	var t *Task
+	var seen map[string]bool
+	var nested []string
+	for _, line := range lines {
+		p, err := parseICalLine(line)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch {
+		case p.Name == "BEGIN" && p.Value == "VTODO" && t == nil:
+			t = &Task{Priority: PriorityNormal}
+			seen = map[string]bool{}
+		case t == nil:
+			continue
+		case p.Name == "BEGIN":
+			nested = append(nested, p.Value)
+		case p.Name == "END" && len(nested) > 0:
+			if !seen[p.Value] {
+				unmapped[p.Value]++
+				seen[p.Value] = true
+			}
+			nested = nested[:len(nested)-1]
+		case len(nested) > 0:
+			continue
+		case p.Name == "END":
+			if err = t.finishICal(); err != nil {
+				return nil, nil, err
+			}
+			tasks = append(tasks, t)
+			t = nil
+		default:
+			known, err := t.applyICal(ws, p)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if !known && !seen[p.Name] {
+				unmapped[p.Name]++
+				seen[p.Name] = true
+			}
+		}
+	}
+
+	return tasks, unmapped, nil
+}
+
+func unfoldICal(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+		} else if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// applyICal applies a VTODO property to the task, returning false if
+// the property isn't converted.
+func (t *Task) applyICal(ws *Workspace, p icalProperty) (bool, error) {
+	loc := ws.Location()
+	var err error
+	switch p.Name {
+	case "UID":
+		t.UID = icalText(p.Value)
+	case "X-UTIL37-ID":
+		t.ID, err = strconv.ParseUint(p.Value, 10, 64)
+	case "SUMMARY":
+		t.Title = icalText(p.Value)
+	case "STATUS":
+		t.Done = p.Value == "COMPLETED" || p.Value == "CANCELLED"
+		t.Cancelled = p.Value == "CANCELLED"
+	case "PRIORITY":
+		var pri int
+		pri, err = strconv.Atoi(p.Value)
+		switch {
+		case pri == 1:
+			t.Priority = PriorityUrgent
+		case pri >= 2 && pri <= 4:
+			t.Priority = PriorityHigh
+		case pri >= 6:
+			t.Priority = PriorityLow
+		default:
+			t.Priority = PriorityNormal
+		}
+	case "CREATED":
+		t.Created, err = p.time(loc)
+	case "COMPLETED":
+		t.Finished, err = p.time(loc)
+	case "DUE":
+		t.Due, err = p.time(loc)
+		t.Due = ws.Date(t.Due)
+	case "DTSTART":
+		t.Wait, err = p.time(loc)
+		t.Wait = ws.Date(t.Wait)
+	case "CATEGORIES":
+		t.Tags = append(t.Tags, icalList(p.Value)...)
+	case "DESCRIPTION":
+		t.Notes = append(t.Notes, strings.Split(icalText(p.Value), "\n")...)
+	case "LAST-MODIFIED":
+		// Cancelled tasks have no COMPLETED time, so this
+		// stands in for it.
+		if t.Finished.IsZero() {
+			t.Finished, err = p.time(loc)
+		}
+	case "DTSTAMP":
+		// This describes the iCalendar object, not the task.
+	default:
+		return false, nil
+	}
+
+	if err != nil {
+		return true, fmt.Errorf("workspace: invalid iCalendar %s %s", p.Name, p.Value)
+	}
+	return true, nil
+}
+
+// finishICal fills in whatever a VTODO left out.
+func (t *Task) finishICal() error {
+	if t.Title == "" {
+		return errors.New("workspace: iCalendar VTODO has no SUMMARY")
+	}
+
+	if t.Created.IsZero() {
+		t.Created = time.Now()
+	}
+
+	if t.Done && t.Finished.IsZero() {
+		t.Finished = time.Now()
+	}
+
+	if !t.Done {
+		t.Finished = time.Time{}
+	}
+
+	if t.ID != 0 && t.UID == fmt.Sprintf("%d%s", t.ID, icalUIDSuffix) {
+		t.UID = ""
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package workspace
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// An ImportResult lists the tasks added to and updated in a workspace
+// by an import, and the number left unchanged.
+type ImportResult struct {
+	Added     []*Task
+	Updated   []*Task
+	Unchanged int
+}
+
+func sameTime(a, b time.Time) bool {
+	return a.Equal(b) || (a.IsZero() && b.IsZero())
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// keepTime returns old if t only gives the date of old, as formats
+// that store dates without times do, or old to the minute, and t
+// otherwise.
+func keepTime(old, t time.Time) time.Time {
+	if old.IsZero() {
+		return t
+	} else if t.Equal(Day(t)) && Day(old).Equal(t) {
+		return old
+	} else if t.Equal(t.Truncate(time.Minute)) && old.Truncate(time.Minute).Equal(t) {
+		return old
+	}
+	return t
+}
+
+// same returns true if the two tasks have the same contents, ignoring
+// their IDs.
+func same(a, b *Task) bool {
+	return a.Title == b.Title && a.Done == b.Done &&
+		a.Cancelled == b.Cancelled && a.Priority == b.Priority &&
+		a.UID == b.UID && a.Parent == b.Parent &&
+		sameTime(a.Created, b.Created) &&
+		sameTime(a.Finished, b.Finished) &&
+		sameTime(a.Due, b.Due) && sameTime(a.Wait, b.Wait) &&
+		sameStrings(a.Tags, b.Tags) && sameStrings(a.Notes, b.Notes)
+}
+
+// replace updates the task old with the contents of t, keeping the
+// tag index up to date.
+func (ws *Workspace) replace(old, t *Task) {
+	for _, tag := range old.Tags {
+		if !contains(tag, t.Tags) {
+			ws.Untag(old.ID, tag)
+		}
+	}
+
+	tags := old.Tags
+	*old = *t
+	old.ID, old.Tags = t.ID, tags
+	for _, tag := range t.Tags {
+		ws.Tag(old.ID, tag)
+	}
+}
+
+// findTask returns the existing task that an imported task refers to,
+// by its ID or, failing that, its UID.
+func (ws *Workspace) findTask(t *Task) *Task {
+	if old, ok := ws.Tasks[t.ID]; ok && t.ID != 0 {
+		return old
+	}
+
+	if t.UID == "" {
+		return nil
+	}
+
+	for _, old := range ws.Tasks {
+		if old.UID == t.UID {
+			return old
+		}
+	}
+	return nil
+}
+
+// Parents gives the parent of each task read from a format with
+// nesting, such as Markdown or Org; a task mapped to nil isn't a
+// subtask. The IDs of the tasks may not be known until they are
+// imported.
+type Parents map[*Task]*Task
+
+// Import adds the tasks to the workspace. A task with the ID or UID of
+// an existing task updates it; any other task is added, with a new ID
+// if it has none. A task in parents is made a subtask of the task it
+// maps to, and any other task that updates an existing task keeps its
+// parent. Unfinished tasks are put on today's list. The tasks passed
+// in may be modified.
+func (ws *Workspace) Import(tasks []*Task, parents Parents) *ImportResult {
+	var r = &ImportResult{}
+	var matched = map[*Task]*Task{}
+	var ids = map[uint64]bool{}
+
+	// IDs are settled first, so that subtasks can refer to tasks
+	// imported alongside them.
+	for _, t := range tasks {
+		t.Tags = normalize(t.Tags)
+		sort.Strings(t.Tags)
+
+		if old := ws.findTask(t); old != nil {
+			t.ID = old.ID
+			matched[t] = old
+			continue
+		}
+
+		if t.ID == 0 || ids[t.ID] {
+			t.ID = NewTaskID()
+			for ws.Tasks[t.ID] != nil || ids[t.ID] {
+				t.ID++
+			}
+		}
+		ids[t.ID] = true
+	}
+
+	for _, t := range tasks {
+		if parent, ok := parents[t]; ok {
+			t.Parent = 0
+			if parent != nil {
+				t.Parent = parent.ID
+			}
+		} else if old := matched[t]; old != nil {
+			t.Parent = old.Parent
+		}
+	}
+
+	for _, t := range tasks {
+		if old := matched[t]; old != nil {
+			if t.UID == "" {
+				t.UID = old.UID
+			}
+
+			t.Created = keepTime(old.Created, t.Created)
+			t.Finished = keepTime(old.Finished, t.Finished)
+			if same(old, t) {
+				r.Unchanged++
+				continue
+			}
+
+			ws.replace(old, t)
+			r.Updated = append(r.Updated, old)
+			continue
+		}
+
+		tags := t.Tags
+		t.Tags = nil
+		ws.Tasks[t.ID] = t
+		for _, tag := range tags {
+			ws.Tag(t.ID, tag)
+		}
+		r.Added = append(r.Added, t)
+	}
+
+	ws.NewEntry()
+	return r
+}
+
+// Formats supported by util37-import and util37-export.
+const (
+	FormatTodoTxt     = "todotxt"
+	FormatTaskwarrior = "taskwarrior"
+	FormatICal        = "ical"
+	FormatCSV         = "csv"
+	FormatOrg         = "org"
+)
+
+// ExchangeUsage describes the import and export formats, for usage
+// messages.
+var ExchangeUsage = `Formats:
+
+    todotxt			todo.txt, one task per line; tags are written
+				as +tag (or @context), and the task's ID,
+				notes and dates as key:value extensions.
+    taskwarrior			Taskwarrior's JSON, as read by "task import"
+				and written by "task export".
+    ical			iCalendar, with a VTODO for each task.
+    csv				Comma-separated values with a header row,
+				for spreadsheets; see -columns and -map.
+    markdown			A Markdown checklist of "- [ ]" items, with
+				subtasks nested beneath their tasks.
+    org				Org mode TODO headlines, with SCHEDULED and
+				DEADLINE dates and nested subtasks.
+`
+
+// An Unmapped counts, for each field that couldn't be converted
+// exactly, the number of tasks affected.
+type Unmapped map[string]int
+
+// Strings describes the unmapped fields, one per string, in order.
+func (u Unmapped) Strings() []string {
+	var fields = make([]string, 0, len(u))
+	for field := range u {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var ss = make([]string, 0, len(fields))
+	for _, field := range fields {
+		plural := "s"
+		if u[field] == 1 {
+			plural = ""
+		}
+		ss = append(ss, fmt.Sprintf("%s (%d task%s)", field, u[field], plural))
+	}
+	return ss
+}
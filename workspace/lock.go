@@ -0,0 +1,43 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrChanged is returned by WriteFile if another program has written
+// the workspace since it was read, so that its changes aren't lost.
+var ErrChanged = errors.New("workspace: the workspace was changed by another program since it was read")
+
+// lockFileName returns the name of the file that is locked while the
+// named workspace is written.
+func lockFileName(name string) string {
+	return filepath.Join(filepath.Dir(FileName(name)), "."+name+".lock")
+}
+
+// lock takes the advisory lock on the named workspace, waiting for any
+// other program holding it, and returns the function to release it.
+func lock(name string) (func(), error) {
+	f, err := os.OpenFile(lockFileName(name), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = flock(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// Closing the file releases the lock.
+	return func() { f.Close() }, nil
+}
+
+// sameFile returns true if the file hasn't been replaced or changed
+// since it was read; a workspace that wasn't read from a file has no
+// file information.
+func sameFile(read, current os.FileInfo) bool {
+	return read != nil && os.SameFile(read, current) &&
+		read.ModTime().Equal(current.ModTime()) &&
+		read.Size() == current.Size()
+}
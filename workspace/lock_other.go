@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package workspace
+
+import "os"
+
+// flock does nothing where flock(2) isn't available; WriteFile still
+// refuses to overwrite a file written since it was read.
+func flock(f *os.File) error {
+	return nil
+}
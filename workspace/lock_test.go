@@ -0,0 +1,56 @@
+package workspace
+
+import "testing"
+
+func TestWriteFileChanged(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	ws, err := ReadFile("test", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = WriteFile(ws); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := ReadFile("test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := ReadFile("test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first.Tasks[1] = NewTask(1, "First")
+	if err = WriteFile(first); err != nil {
+		t.Fatal(err)
+	}
+
+	// A workspace can be written again after it has been written.
+	first.Tasks[2] = NewTask(2, "Second")
+	if err = WriteFile(first); err != nil {
+		t.Fatal(err)
+	}
+
+	second.Tasks[3] = NewTask(3, "Lost")
+	if err = WriteFile(second); err != ErrChanged {
+		t.Fatalf("writing a stale workspace returned %v, want ErrChanged", err)
+	}
+
+	// A new workspace doesn't replace one written in the meantime.
+	if err = WriteFile(NewWorkspace("test")); err != ErrChanged {
+		t.Fatalf("writing a new workspace returned %v, want ErrChanged", err)
+	}
+
+	ws, err = ReadFile("test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ws.Tasks) != 2 || ws.Tasks[3] != nil {
+		t.Errorf("workspace has tasks %v, want 1 and 2", ws.Tasks)
+	}
+}
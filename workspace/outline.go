@@ -0,0 +1,608 @@
+package workspace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tasks are written to Markdown as checklist items and to Org as TODO
+// headlines, with subtasks nested beneath the task they belong to:
+//
+//	- [ ] [#A] Title #tag <!-- id:N created:2006-01-02T15:04 -->
+//	  DEADLINE: <2006-01-02 Mon> SCHEDULED: <2006-01-02 Mon>
+//	  A note.
+//
+//	* TODO [#A] Title :tag:
+//	  DEADLINE: <2006-01-02 Mon> SCHEDULED: <2006-01-02 Mon>
+//	  :PROPERTIES:
+//	  :UTIL37_ID: N
+//	  :CREATED:  [2006-01-02 Mon 15:04]
+//	  :END:
+//	  A note.
+//
+// Completed tasks are checked, [x], or DONE, and cancelled tasks are
+// marked [-] or CANCELLED, with a CLOSED timestamp on the planning
+// line. Priorities use the letters of todo.txt. DEADLINE is the due
+// date and SCHEDULED the day the task waits until. Org tags can't
+// contain slashes, so hierarchical tags are written with # instead.
+//
+// When reading, Org checklist items ("- [ ]") are read as subtasks of
+// their headline, headlines without a TODO keyword are not tasks, and
+// an Org :ID: property is kept as the task's UID.
+//
+// Each note is written as a paragraph, with a blank line between
+// notes. Note lines that would be read as something else, such as
+// checklist items, headlines or planning lines, are escaped with a
+// backslash, as are blank lines within a note and words in titles that
+// would be read as tags or as priority or statistics cookies.
+
+var (
+	checkItemRegexp  = regexp.MustCompile(`^(\s*)(?:[-+*]|\d+[.)])\s+\[([ xX-])\]\s+(.*)$`)
+	orgHeadRegexp    = regexp.MustCompile(`^(\*+)\s+(.*)$`)
+	orgTagsRegexp    = regexp.MustCompile(`\s+:((?:[^\s:]+:)+)$`)
+	priCookieRegexp  = regexp.MustCompile(`^\[#([A-Z])\]\s*`)
+	statCookieRegexp = regexp.MustCompile(`(?:^|\s+)\[(?:\d+/\d+|\d+%)\]`)
+	mdCommentRegexp  = regexp.MustCompile(`\s*<!--\s*(.*?)\s*-->$`)
+	planStartRegexp  = regexp.MustCompile(`^(?:SCHEDULED|DEADLINE|CLOSED):`)
+	planningRegexp   = regexp.MustCompile(`(SCHEDULED|DEADLINE|CLOSED):\s*([<\[][^>\]]*[>\]])`)
+	orgStampRegexp   = regexp.MustCompile(`^[<\[](\d{4}-\d{2}-\d{2})(?:\s+[^\s\d>\]]+)?(?:\s+(\d{1,2}:\d{2}))?`)
+	drawerRegexp     = regexp.MustCompile(`^:([\w-]+):(?:\s+(.*))?$`)
+)
+
+const (
+	orgDateFormat  = "2006-01-02 Mon"
+	orgTimeFormat  = "2006-01-02 Mon 15:04"
+	mdCreateFormat = "2006-01-02T15:04"
+)
+
+// orgKeywords are the TODO keywords that mark headlines as tasks.
+var orgKeywords = map[string]bool{
+	"TODO": true, "NEXT": true, "STARTED": true, "WAITING": true,
+	"HOLD": true, "DONE": true, "CANCELLED": true, "CANCELED": true,
+}
+
+// outlineTree returns the tasks that aren't subtasks of another of the
+// tasks, and the subtasks of each task, keeping the order of tasks.
+func outlineTree(tasks []*Task) ([]*Task, map[uint64][]*Task) {
+	var roots []*Task
+	var ids = map[uint64]bool{}
+	var children = map[uint64][]*Task{}
+
+	for _, t := range tasks {
+		ids[t.ID] = true
+	}
+
+	for _, t := range tasks {
+		if t.Parent != 0 && t.Parent != t.ID && ids[t.Parent] {
+			children[t.Parent] = append(children[t.Parent], t)
+		} else {
+			roots = append(roots, t)
+		}
+	}
+	return roots, children
+}
+
+// writeOutline writes each task and its subtasks with the line
+// function, which is given the task's depth.
+func writeOutline(w io.Writer, tasks []*Task, lines func(*Task, int) []string) error {
+	roots, children := outlineTree(tasks)
+	seen := map[uint64]bool{}
+
+	var write func(*Task, int) error
+	write = func(t *Task, depth int) error {
+		if seen[t.ID] {
+			return nil
+		}
+		seen[t.ID] = true
+
+		for _, line := range lines(t, depth) {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+
+		for _, child := range children[t.ID] {
+			if err := write(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, t := range roots {
+		if err := write(t, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planning returns the task's Org planning line, or an empty string
+// if it has no dates to show there.
+func (t *Task) planning() string {
+	var words []string
+	if !t.Due.IsZero() {
+		words = append(words, "DEADLINE: <"+t.Due.Format(orgDateFormat)+">")
+	}
+
+	if !t.Wait.IsZero() {
+		words = append(words, "SCHEDULED: <"+t.Wait.Format(orgDateFormat)+">")
+	}
+
+	if t.Done {
+		words = append(words, "CLOSED: ["+t.Finished.Format(orgTimeFormat)+"]")
+	}
+	return strings.Join(words, " ")
+}
+
+// bodyLines returns the planning line and the notes, indented.
+func (t *Task) bodyLines(indent string, extra []string) []string {
+	var lines []string
+	if planning := t.planning(); planning != "" {
+		lines = append(lines, indent+planning)
+	}
+
+	for _, line := range extra {
+		lines = append(lines, indent+line)
+	}
+
+	for i, note := range t.Notes {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(note), "\n") {
+			lines = append(lines, indent+noteLine(strings.TrimSpace(line)))
+		}
+	}
+	return lines
+}
+
+// noteLine escapes a line of a note that would otherwise be read as a
+// blank line, a checklist item, a headline, a drawer or a planning line.
+func noteLine(line string) string {
+	if line == "" || strings.HasPrefix(line, `\`) ||
+		checkItemRegexp.MatchString(line) ||
+		orgHeadRegexp.MatchString(line) ||
+		drawerRegexp.MatchString(line) ||
+		planStartRegexp.MatchString(line) {
+		return `\` + line
+	}
+	return line
+}
+
+// escapeTitle escapes the words of a title that would be read as a
+// priority cookie, a statistics cookie, tags or an escape: #tags in
+// Markdown, and a trailing :tag: group in Org.
+func escapeTitle(title string, org bool) string {
+	words := strings.Fields(title)
+	for i, word := range words {
+		switch {
+		case strings.HasPrefix(word, `\`),
+			i == 0 && priCookieRegexp.MatchString(word),
+			statCookieRegexp.MatchString(word),
+			!org && len(word) > 1 && word[0] == '#',
+			org && i == len(words)-1 && orgTagsRegexp.MatchString(" "+word):
+			words[i] = `\` + word
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// unescapeTitle joins the words of a title, removing the escapes
+// added by escapeTitle.
+func unescapeTitle(words []string) string {
+	for i, word := range words {
+		words[i] = strings.TrimPrefix(word, `\`)
+	}
+	return strings.Join(words, " ")
+}
+
+func (t *Task) markdownLines(depth int) []string {
+	indent := strings.Repeat("  ", depth)
+	box := " "
+	if t.Cancelled {
+		box = "-"
+	} else if t.Done {
+		box = "x"
+	}
+
+	words := []string{indent + "- [" + box + "]"}
+	if letter := todoPriorities[t.Priority]; letter != "" {
+		words = append(words, "[#"+letter+"]")
+	}
+	words = append(words, escapeTitle(t.Title, false))
+
+	for _, tag := range t.Tags {
+		words = append(words, "#"+tag)
+	}
+
+	comment := fmt.Sprintf("<!-- id:%d created:%s", t.ID,
+		t.Created.Format(mdCreateFormat))
+	if t.UID != "" {
+		comment += " uid:" + url.PathEscape(t.UID)
+	}
+	words = append(words, comment+" -->")
+
+	lines := []string{strings.Join(words, " ")}
+	return append(lines, t.bodyLines(indent+"  ", nil)...)
+}
+
+func (t *Task) orgLines(depth int) []string {
+	keyword := "TODO"
+	if t.Cancelled {
+		keyword = "CANCELLED"
+	} else if t.Done {
+		keyword = "DONE"
+	}
+
+	words := []string{strings.Repeat("*", depth+1), keyword}
+	if letter := todoPriorities[t.Priority]; letter != "" {
+		words = append(words, "[#"+letter+"]")
+	}
+	words = append(words, escapeTitle(t.Title, true))
+
+	if len(t.Tags) > 0 {
+		var tags []string
+		for _, tag := range t.Tags {
+			tags = append(tags, strings.Replace(tag, "/", "#", -1))
+		}
+		words = append(words, ":"+strings.Join(tags, ":")+":")
+	}
+
+	props := []string{
+		":PROPERTIES:",
+		fmt.Sprintf(":UTIL37_ID: %d", t.ID),
+		":CREATED:  [" + t.Created.Format(orgTimeFormat) + "]",
+	}
+	if t.UID != "" {
+		props = append(props, ":ID:       "+t.UID)
+	}
+	props = append(props, ":END:")
+
+	lines := []string{strings.Join(words, " ")}
+	return append(lines, t.bodyLines(strings.Repeat(" ", depth+2), props)...)
+}
+
+// WriteMarkdown writes the tasks as a Markdown checklist.
+func WriteMarkdown(w io.Writer, tasks []*Task) error {
+	return writeOutline(w, tasks, (*Task).markdownLines)
+}
+
+// WriteOrg writes the tasks as Org TODO headlines.
+func WriteOrg(w io.Writer, tasks []*Task) error {
+	return writeOutline(w, tasks, (*Task).orgLines)
+}
+
+func orgStamp(s string, loc *time.Location) (time.Time, error) {
+	subs := orgStampRegexp.FindStringSubmatch(s)
+	if subs == nil {
+		return time.Time{}, errors.New("workspace: invalid timestamp " + s)
+	}
+
+	if subs[2] == "" {
+		return time.ParseInLocation(DateFormat, subs[1], loc)
+	}
+	return time.ParseInLocation(DateFormat+" 15:04", subs[1]+" "+subs[2], loc)
+}
+
+// An outlineItem is a task or heading that later items may be nested
+// beneath; task is nil for headings that aren't tasks.
+type outlineItem struct {
+	level int
+	task  *Task
+}
+
+// outlineReader holds the state of a Markdown or Org file being read.
+type outlineReader struct {
+	ws       *Workspace // whose time zone times are read in
+	org      bool
+	tasks    []*Task
+	parents  Parents
+	unmapped Unmapped
+	stack    []outlineItem
+
+	current *Task // the task that body lines belong to
+	indent  int   // the indentation of the current checklist item
+	heading *Task // the Org headline that the current item is under
+	drawer  string
+	joined  bool // whether the next body line continues the last note
+}
+
+// push adds an item at the given level, nested beneath the closest
+// item above it with a lower level.
+func (rd *outlineReader) push(level int, t *Task) {
+	for len(rd.stack) > 0 && rd.stack[len(rd.stack)-1].level >= level {
+		rd.stack = rd.stack[:len(rd.stack)-1]
+	}
+
+	if t != nil {
+		rd.parents[t] = nil
+		if len(rd.stack) > 0 {
+			rd.parents[t] = rd.stack[len(rd.stack)-1].task
+		}
+		rd.tasks = append(rd.tasks, t)
+	}
+	rd.stack = append(rd.stack, outlineItem{level: level, task: t})
+	rd.current, rd.drawer, rd.joined = t, "", false
+}
+
+// newItem returns a task with the given state and title, removing the
+// priority and statistics cookies from the title.
+func newItem(state, title string) (*Task, string) {
+	t := &Task{Priority: PriorityNormal, Created: time.Now()}
+	switch state {
+	case "x", "X", "DONE":
+		t.Done = true
+	case "-", "CANCELLED", "CANCELED":
+		t.Done, t.Cancelled = true, true
+	}
+
+	if t.Done {
+		t.Finished = time.Now()
+	}
+
+	if subs := priCookieRegexp.FindStringSubmatch(title); subs != nil {
+		t.Priority = todoPriority(subs[1])
+		title = title[len(subs[0]):]
+	}
+	return t, strings.TrimSpace(statCookieRegexp.ReplaceAllString(title, ""))
+}
+
+// markdownTitle sets the task's title from the text of a checklist
+// item, taking trailing #tags and the comment written by WriteMarkdown.
+func (rd *outlineReader) markdownTitle(t *Task, title string) error {
+	if subs := mdCommentRegexp.FindStringSubmatch(title); subs != nil {
+		title = title[:len(title)-len(subs[0])]
+		for _, word := range strings.Fields(subs[1]) {
+			if err := rd.mdField(t, word); err != nil {
+				return err
+			}
+		}
+	}
+
+	words := strings.Fields(title)
+	for len(words) > 1 {
+		word := words[len(words)-1]
+		if len(word) < 2 || word[0] != '#' {
+			break
+		}
+		t.Tags = append([]string{word[1:]}, t.Tags...)
+		words = words[:len(words)-1]
+	}
+
+	t.Title = unescapeTitle(words)
+	return nil
+}
+
+func (rd *outlineReader) mdField(t *Task, word string) error {
+	var err error
+	key, value := word, ""
+	if i := strings.Index(word, ":"); i > 0 {
+		key, value = word[:i], word[i+1:]
+	}
+
+	switch key {
+	case "id":
+		t.ID, err = strconv.ParseUint(value, 10, 64)
+	case "created":
+		t.Created, err = time.ParseInLocation(mdCreateFormat, value, rd.ws.Location())
+	case "uid":
+		t.UID, err = url.PathUnescape(value)
+	default:
+		rd.unmapped[key]++
+	}
+
+	if err != nil {
+		return errors.New("workspace: invalid checklist field " + word)
+	}
+	return nil
+}
+
+// orgHeadline reads a headline, which is a task if it starts with a
+// TODO keyword.
+func (rd *outlineReader) orgHeadline(level int, text string) error {
+	words := strings.SplitN(text, " ", 2)
+	if !orgKeywords[words[0]] {
+		rd.push(level, nil)
+		rd.heading = nil
+		return nil
+	}
+
+	var title string
+	if len(words) > 1 {
+		title = words[1]
+	}
+
+	var tags []string
+	if subs := orgTagsRegexp.FindStringSubmatch(title); subs != nil {
+		title = title[:len(title)-len(subs[0])]
+		for _, tag := range strings.Split(strings.Trim(subs[1], ":"), ":") {
+			tags = append(tags, strings.Replace(tag, "#", "/", -1))
+		}
+	}
+
+	t, title := newItem(words[0], title)
+	t.Title, t.Tags = unescapeTitle(strings.Fields(title)), tags
+	rd.push(level, t)
+	rd.heading, rd.indent = t, -1
+	return nil
+}
+
+// body handles a line that isn't a headline or checklist item, which
+// belongs to the current task if there is one.
+func (rd *outlineReader) body(line string) error {
+	text := strings.TrimSpace(line)
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	if text == "" {
+		rd.joined = false
+		return nil
+	}
+
+	if rd.current != nil && rd.indent >= 0 && indent <= rd.indent {
+		// Text that isn't indented beneath a checklist item ends
+		// it: in Org, it belongs to the headline again, and in
+		// Markdown it ends the items at its indentation.
+		rd.current = nil
+		if rd.org {
+			rd.current, rd.indent, rd.joined = rd.heading, -1, false
+		} else {
+			rd.push(indent, nil)
+		}
+	}
+
+	t := rd.current
+	if t == nil {
+		return nil
+	}
+
+	if rd.drawer != "" {
+		return rd.drawerLine(t, text)
+	}
+
+	if subs := drawerRegexp.FindStringSubmatch(text); subs != nil && subs[2] == "" {
+		rd.drawer, rd.joined = subs[1], false
+		if rd.drawer != "PROPERTIES" {
+			rd.unmapped[strings.ToLower(rd.drawer)]++
+		}
+		return nil
+	}
+
+	if planStartRegexp.MatchString(text) {
+		rd.joined = false
+		return rd.planningLine(t, text)
+	}
+
+	text = strings.TrimPrefix(text, `\`)
+	if rd.joined && len(t.Notes) > 0 {
+		t.Notes[len(t.Notes)-1] += "\n" + text
+	} else {
+		t.Notes = append(t.Notes, text)
+	}
+	rd.joined = true
+	return nil
+}
+
+func (rd *outlineReader) planningLine(t *Task, text string) error {
+	for _, subs := range planningRegexp.FindAllStringSubmatch(text, -1) {
+		stamp, err := orgStamp(subs[2], rd.ws.Location())
+		if err != nil {
+			return err
+		}
+
+		switch subs[1] {
+		case "DEADLINE":
+			t.Due = rd.ws.Date(stamp)
+		case "SCHEDULED":
+			t.Wait = rd.ws.Date(stamp)
+		case "CLOSED":
+			if t.Done {
+				t.Finished = stamp
+			}
+		}
+	}
+	return nil
+}
+
+func (rd *outlineReader) drawerLine(t *Task, text string) error {
+	if text == ":END:" {
+		rd.drawer = ""
+		return nil
+	} else if rd.drawer != "PROPERTIES" {
+		return nil
+	}
+
+	subs := drawerRegexp.FindStringSubmatch(text)
+	if subs == nil {
+		return nil
+	}
+
+	var err error
+	switch subs[1] {
+	case "UTIL37_ID":
+		t.ID, err = strconv.ParseUint(subs[2], 10, 64)
+	case "CREATED":
+		t.Created, err = orgStamp(subs[2], rd.ws.Location())
+	case "ID":
+		t.UID = subs[2]
+	default:
+		rd.unmapped[subs[1]]++
+	}
+
+	if err != nil {
+		return errors.New("workspace: invalid property " + text)
+	}
+	return nil
+}
+
+func (rd *outlineReader) line(line string) error {
+	line = strings.TrimRight(line, " \t\r")
+	if subs := orgHeadRegexp.FindStringSubmatch(line); rd.org && subs != nil {
+		return rd.orgHeadline(len(subs[1]), subs[2])
+	}
+
+	subs := checkItemRegexp.FindStringSubmatch(line)
+	if subs == nil {
+		return rd.body(line)
+	}
+
+	indent := len(strings.Replace(subs[1], "\t", "    ", -1))
+	t, title := newItem(subs[2], subs[3])
+	if rd.org {
+		t.Title = title
+	} else if err := rd.markdownTitle(t, title); err != nil {
+		return err
+	}
+
+	// Checklist items in Org files are nested beneath the headlines,
+	// whatever their indentation.
+	level := indent
+	if rd.org {
+		level += 1000
+	}
+	rd.push(level, t)
+	rd.indent = indent
+	return nil
+}
+
+func (ws *Workspace) readOutline(r io.Reader, org bool) ([]*Task, Parents, Unmapped, error) {
+	rd := &outlineReader{ws: ws, org: org, parents: Parents{}, unmapped: Unmapped{}}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		if err := rd.line(scanner.Text()); err != nil {
+			return nil, nil, nil, fmt.Errorf("%v (line %d)", err, n)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, t := range rd.tasks {
+		if t.Title == "" {
+			return nil, nil, nil, errors.New("workspace: checklist item has no title")
+		}
+	}
+	return rd.tasks, rd.parents, rd.unmapped, nil
+}
+
+// ReadMarkdown reads the checklist items in a Markdown file, and the
+// task each is nested beneath, reading times in the workspace's time
+// zone.
+func (ws *Workspace) ReadMarkdown(r io.Reader) ([]*Task, Parents, Unmapped, error) {
+	return ws.readOutline(r, false)
+}
+
+// ReadOrg reads the TODO headlines and checklist items in an Org file,
+// and the task each is nested beneath, reading times in the
+// workspace's time zone.
+func (ws *Workspace) ReadOrg(r io.Reader) ([]*Task, Parents, Unmapped, error) {
+	return ws.readOutline(r, true)
+}
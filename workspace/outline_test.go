@@ -0,0 +1,101 @@
+package workspace
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func outlineTestTasks() []*Task {
+	issue := todoTestTask(1, "Fix issue #123")
+	issue.Tags = []string{"work", "client/acme"}
+	issue.Priority = PriorityHigh
+
+	noted := todoTestTask(2, `Write the \notes`)
+	noted.Notes = []string{
+		"First line\nsecond line",
+		"- [ ] not an item\n* not a headline\nDEADLINE: <2015-08-09 Sun>",
+		":PROPERTIES:\n\\backslash\n\nafter a blank line",
+		"A last note",
+	}
+	noted.Due = time.Date(2015, 8, 10, 0, 0, 0, 0, time.Local)
+	noted.Wait = time.Date(2015, 8, 5, 0, 0, 0, 0, time.Local)
+
+	done := todoTestTask(3, "Ship the release")
+	done.Done = true
+	done.Finished = time.Date(2015, 8, 3, 12, 30, 0, 0, time.Local)
+	done.UID = "release-1"
+	done.Notes = []string{"Shipped."}
+
+	sub := todoTestTask(4, "Tag the release #v1")
+	sub.Parent = 3
+
+	return []*Task{
+		issue, noted, done, sub,
+		todoTestTask(5, "Fix :bug:"),
+		todoTestTask(6, "[#A] thing"),
+		todoTestTask(7, "Halfway [1/2] there [50%]"),
+	}
+}
+
+func testOutlineRoundTrip(t *testing.T, write func(io.Writer, []*Task) error,
+	read func(io.Reader) ([]*Task, Parents, Unmapped, error)) {
+	tasks := outlineTestTasks()
+
+	var buf bytes.Buffer
+	if err := write(&buf, tasks); err != nil {
+		t.Fatal(err)
+	}
+	text := buf.String()
+
+	have, parents, _, err := read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(have) != len(tasks) {
+		t.Fatalf("read %d tasks, want %d:\n%s", len(have), len(tasks), text)
+	}
+
+	for i := range tasks {
+		var parent uint64
+		if p := parents[have[i]]; p != nil {
+			parent = p.ID
+		}
+
+		if parent != tasks[i].Parent {
+			t.Errorf("task %d is beneath %d, want %d", i, parent, tasks[i].Parent)
+		}
+
+		have[i].Parent = tasks[i].Parent
+		if have[i].ID != tasks[i].ID || !same(have[i], tasks[i]) {
+			t.Errorf("task %d changed:\n\thave %+v\n\twant %+v\n%s",
+				i, have[i], tasks[i], text)
+		}
+	}
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	testOutlineRoundTrip(t, WriteMarkdown, NewWorkspace("test").ReadMarkdown)
+}
+
+func TestOrgRoundTrip(t *testing.T) {
+	testOutlineRoundTrip(t, WriteOrg, NewWorkspace("test").ReadOrg)
+}
+
+func TestReadOrgTimeZone(t *testing.T) {
+	ws := testWorkspace(t, 0)
+	org := "* TODO Call Mom\n  DEADLINE: <2015-08-02 Sun> SCHEDULED: <2015-08-02 Sun>\n"
+	tasks, _, _, err := ws.ReadOrg(strings.NewReader(org))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, day := range map[string]time.Time{"due": tasks[0].Due, "wait": tasks[0].Wait} {
+		if got := ws.DayOf(day).Format(DateFormat); got != "2015-08-02" {
+			t.Errorf("%s day is %s, want 2015-08-02", name, got)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Queries maps a query name to the filter words it expands to. Saved
+// queries may be referenced in any filter position as @name, and may
+// themselves reference other saved queries.
+type Queries map[string][]string
+
+var (
+	queryNameRegexp = regexp.MustCompile(`^\w[\w-]*$`)
+	queryRefRegexp  = regexp.MustCompile(`^@(.*)$`)
+)
+
+// ValidQueryName returns true if name may be used as a saved query name.
+func ValidQueryName(name string) bool {
+	return queryNameRegexp.MatchString(name)
+}
+
+// Names returns the sorted list of query names.
+func (qs Queries) Names() []string {
+	var names = make([]string, 0, len(qs))
+	for name := range qs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String returns the query's words joined into a single string.
+func (qs Queries) String(name string) string {
+	return strings.Join(qs[name], " ")
+}
+
+// QueryRecord is the JSON representation of a saved query.
+//
+//	name       the query's name, without the leading @
+//	query      the query's words, as saved
+//	expansion  the words after expanding saved queries; absent if
+//	           the query can't be expanded
+type QueryRecord struct {
+	Name      string   `json:"name"`
+	Query     []string `json:"query"`
+	Expansion []string `json:"expansion,omitempty"`
+}
+
+// Record returns the JSON representation of the named query, expanded
+// against the query sets.
+func (qs Queries) Record(name string, sets ...Queries) QueryRecord {
+	r := QueryRecord{Name: name, Query: qs[name]}
+	if r.Query == nil {
+		r.Query = []string{}
+	}
+
+	words, err := ExpandQueries([]string{"@" + name}, sets...)
+	if err == nil {
+		r.Expansion = words
+	}
+	return r
+}
+
+// SplitQuery breaks each argument into whitespace-separated filter
+// words, so that a query may be given either as a single quoted
+// string or as separate arguments.
+func SplitQuery(args []string) []string {
+	var words []string
+	for _, arg := range args {
+		words = append(words, strings.Fields(arg)...)
+	}
+	return words
+}
+
+// QueriesFileName returns the name of the file containing the global
+// saved queries. It deliberately lacks the .json extension so that it
+// can't collide with a workspace.
+func QueriesFileName() string {
+	basePath := os.Getenv("HOME")
+	return filepath.Join(basePath, ".config", "util37", "queries")
+}
+
+// ReadQueries loads the global saved queries. A missing file is not an
+// error; it simply means there are no global queries.
+func ReadQueries() (Queries, error) {
+	var qs = Queries{}
+
+	in, err := ioutil.ReadFile(QueriesFileName())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return qs, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(in, &qs)
+	if err != nil {
+		return nil, err
+	}
+
+	return qs, nil
+}
+
+// WriteQueries stores the global saved queries to disk.
+func WriteQueries(qs Queries) error {
+	out, err := json.MarshalIndent(qs, "", "        ")
+	if err != nil {
+		return err
+	}
+
+	name := QueriesFileName()
+	err = os.MkdirAll(filepath.Dir(name), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(name, out, 0600)
+}
+
+// expander resolves @name references against a list of query sets,
+// searched in order.
+type expander struct {
+	sets  []Queries
+	stack []string
+}
+
+func (x *expander) lookup(name string) ([]string, bool) {
+	for _, qs := range x.sets {
+		if words, ok := qs[name]; ok {
+			return words, true
+		}
+	}
+	return nil, false
+}
+
+func (x *expander) expand(args []string) ([]string, error) {
+	var out []string
+	for _, word := range args {
+		word = strings.TrimSpace(word)
+		if !queryRefRegexp.MatchString(word) {
+			out = append(out, word)
+			continue
+		}
+
+		name := word[1:]
+		for i := range x.stack {
+			if x.stack[i] == name {
+				cycle := append(x.stack[i:], name)
+				return nil, errors.New("workspace: query cycle @" +
+					strings.Join(cycle, " -> @"))
+			}
+		}
+
+		words, ok := x.lookup(name)
+		if !ok {
+			return nil, errors.New("workspace: unknown query " + word)
+		}
+
+		x.stack = append(x.stack, name)
+		words, err := x.expand(words)
+		x.stack = x.stack[:len(x.stack)-1]
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, words...)
+	}
+
+	return out, nil
+}
+
+// ExpandQueries replaces every @name in args with the words of the
+// named query, searching each set of queries in order. Expansion is
+// recursive; a query that references itself, directly or indirectly,
+// is an error.
+func ExpandQueries(args []string, sets ...Queries) ([]string, error) {
+	x := &expander{sets: sets}
+	return x.expand(args)
+}
+
+// ExpandQuery expands saved query references in args. Queries saved in
+// the workspace take precedence over global queries of the same name.
+func (ws *Workspace) ExpandQuery(args []string) ([]string, error) {
+	global, err := ReadQueries()
+	if err != nil {
+		return nil, err
+	}
+
+	return ExpandQueries(args, ws.Queries, global)
+}
+
+// Query expands any saved query references in args and builds the
+// resulting filter chain against the workspace, which filters such as
+// stale: need.
+func (ws *Workspace) Query(args []string, status CompletionStatus) (*FilterChain, error) {
+	words, err := ws.ExpandQuery(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return processQuery(words, status, ws)
+}
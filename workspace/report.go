@@ -0,0 +1,396 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// ReportVersion is the version of the JSON report schema. It is only
+// incremented for changes that would break existing consumers; new
+// fields may be added without changing it.
+const ReportVersion = 1
+
+var priorityNames = map[Priority]string{
+	PriorityUnknown: "unknown",
+	PriorityLow:     "low",
+	PriorityNormal:  "normal",
+	PriorityHigh:    "high",
+	PriorityUrgent:  "urgent",
+}
+
+// ParsePriority returns the priority given by either a priority
+// specifier, such as H, or its name in JSON records, such as high.
+func ParsePriority(s string) Priority {
+	for pri, name := range priorityNames {
+		if name == s {
+			return pri
+		}
+	}
+	return PriorityFromString(s)
+}
+
+// TaskRecord is the stable JSON representation of a task.
+//
+//	id         the task's identifier, as a decimal string, since it
+//	           doesn't fit in a JSON number without loss of precision
+//	title      the task's title
+//	status     "open", "done" or "cancelled"
+//	priority   "unknown", "low", "normal", "high" or "urgent"
+//	created    creation time, in RFC 3339 format
+//	finished   completion or cancellation time; absent for open
+//	           tasks
+//	due        due date; absent if the task has none
+//	wait       the day the task is waiting until; absent unless the
+//	           task is snoozed or scheduled to start later
+//	carried    the number of times the task has been carried over
+//	           from one day's list to the next
+//	tags       the task's tags; always present, possibly empty
+//	notes      the task's annotations; always present, possibly empty
+//	change     in a comparison of two days, how the task changed:
+//	           "added", "completed", "carried" or "dropped"; absent
+//	           otherwise
+type TaskRecord struct {
+	ID       uint64     `json:"id,string"`
+	Title    string     `json:"title"`
+	Status   string     `json:"status"`
+	Priority string     `json:"priority"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Due      *time.Time `json:"due,omitempty"`
+	Wait     *time.Time `json:"wait,omitempty"`
+	Carried  int        `json:"carried"`
+	Tags     []string   `json:"tags"`
+	Notes    []string   `json:"notes"`
+	Change   string     `json:"change,omitempty"`
+}
+
+// Record returns the JSON representation of the task.
+func (t *Task) Record() TaskRecord {
+	r := TaskRecord{
+		ID:       t.ID,
+		Title:    t.Title,
+		Status:   "open",
+		Priority: priorityNames[t.Priority],
+		Created:  t.Created,
+		Tags:     t.Tags,
+		Notes:    t.Notes,
+	}
+
+	if t.Cancelled {
+		r.Status = "cancelled"
+		finished := t.Finished
+		r.Finished = &finished
+	} else if t.Done {
+		r.Status = "done"
+		finished := t.Finished
+		r.Finished = &finished
+	}
+
+	if !t.Due.IsZero() {
+		due := t.Due
+		r.Due = &due
+	}
+
+	if !t.Done && !t.Wait.IsZero() {
+		wait := t.Wait
+		r.Wait = &wait
+	}
+
+	if r.Tags == nil {
+		r.Tags = []string{}
+	}
+
+	if r.Notes == nil {
+		r.Notes = []string{}
+	}
+
+	return r
+}
+
+// EntryRecord is the JSON representation of an entry.
+//
+//	id          the entry's identifier, as a decimal string
+//	date        the day the entry is for
+//	focus       the number of tasks at the top of the entry that are
+//	            the day's focus
+//	backfilled  true if the workspace wasn't used that day
+//	count       the number of tasks in the entry
+//	tasks       the entry's tasks, in the day's order; absent in
+//	            lists of entries
+type EntryRecord struct {
+	ID         uint64       `json:"id,string"`
+	Date       time.Time    `json:"date"`
+	Focus      int          `json:"focus"`
+	Backfilled bool         `json:"backfilled"`
+	Count      int          `json:"count"`
+	Tasks      []TaskRecord `json:"tasks,omitempty"`
+}
+
+// EntryRecord returns the JSON representation of an entry, with its
+// tasks if withTasks is true.
+func (ws *Workspace) EntryRecord(id uint64, withTasks bool) EntryRecord {
+	e := ws.Entries[id]
+	r := EntryRecord{
+		ID:         id,
+		Date:       e.Date,
+		Focus:      e.Focus,
+		Backfilled: e.Backfilled,
+		Count:      len(e.Tasks),
+	}
+
+	if !withTasks {
+		return r
+	}
+
+	r.Tasks = make([]TaskRecord, 0, len(e.Tasks))
+	carried := ws.CarryCounts()
+	for _, tid := range e.Tasks {
+		task, ok := ws.Tasks[tid]
+		if !ok {
+			continue
+		}
+
+		record := task.Record()
+		record.Carried = carried[tid]
+		r.Tasks = append(r.Tasks, record)
+	}
+	return r
+}
+
+// ReportHeader describes the query that produced a report.
+//
+//	version     the schema version; see ReportVersion
+//	workspace   the workspace name
+//	report      the kind of report: "today", "review", "day" or "diff"
+//	generated   when the report was produced
+//	query       the filter words, after expanding saved queries
+//	start, end  the time range selected by the query; either may be
+//	            absent if the query is unbounded
+//	range       a description of the time range, possibly empty
+//	count       the number of tasks in the report
+//	open, done  the number of open and completed tasks
+type ReportHeader struct {
+	Version   int        `json:"version"`
+	Workspace string     `json:"workspace"`
+	Report    string     `json:"report"`
+	Generated time.Time  `json:"generated"`
+	Query     []string   `json:"query"`
+	Start     *time.Time `json:"start,omitempty"`
+	End       *time.Time `json:"end,omitempty"`
+	Range     string     `json:"range"`
+	Count     int        `json:"count"`
+	Open      int        `json:"open"`
+	Done      int        `json:"done"`
+}
+
+// A Report is a list of tasks along with the query that selected
+// them.
+type Report struct {
+	ReportHeader
+	Tasks []TaskRecord `json:"tasks"`
+}
+
+// NewReport builds a report of the given kind from the tasks selected
+// by the filter chain.
+func NewReport(kind string, ws *Workspace, c *FilterChain, tasks []*Task) *Report {
+	r := &Report{
+		ReportHeader: ReportHeader{
+			Version:   ReportVersion,
+			Workspace: ws.Name,
+			Report:    kind,
+			Generated: time.Now(),
+			Query:     c.Query(),
+			Range:     c.TimeRange(),
+			Count:     len(tasks),
+		},
+		Tasks: make([]TaskRecord, 0, len(tasks)),
+	}
+
+	if r.Query == nil {
+		r.Query = []string{}
+	}
+
+	if start := c.Start(); !start.IsZero() {
+		r.Start = &start
+	}
+
+	if end := c.End(); !end.IsZero() {
+		r.End = &end
+	}
+
+	carried := ws.CarryCounts()
+	for _, task := range tasks {
+		r.add(task, carried[task.ID], "")
+	}
+
+	return r
+}
+
+func (r *Report) add(task *Task, carried int, change string) {
+	if task.Done {
+		r.Done++
+	} else {
+		r.Open++
+	}
+
+	record := task.Record()
+	record.Carried = carried
+	record.Change = change
+	r.Tasks = append(r.Tasks, record)
+}
+
+// newDayReport starts a report covering the days from start to end.
+func (ws *Workspace) newDayReport(kind string, start, end time.Time) *Report {
+	r := &Report{
+		ReportHeader: ReportHeader{
+			Version:   ReportVersion,
+			Workspace: ws.Name,
+			Report:    kind,
+			Generated: time.Now(),
+			Query:     []string{},
+			Start:     &start,
+			End:       &end,
+		},
+		Tasks: []TaskRecord{},
+	}
+
+	if start.Equal(end) {
+		r.Range = "on " + start.Format(DateFormat)
+	} else {
+		r.Range = "between " + start.Format(DateFormat) + " and " +
+			end.Format(DateFormat)
+	}
+	return r
+}
+
+// NewDayReport builds a report of an entry's list of tasks, in the
+// entry's order, with each task as it stood at the end of the day.
+func (ws *Workspace) NewDayReport(id uint64) *Report {
+	e := ws.Entries[id]
+	day := ws.EntryDay(id)
+	r := ws.newDayReport("day", day, day)
+
+	carried := ws.CarryCounts()
+	for _, tid := range e.Tasks {
+		if task, ok := ws.Tasks[tid]; ok {
+			r.add(task.AsOf(ws.DayEnd(day)), carried[tid], "")
+		}
+	}
+
+	r.Count = len(r.Tasks)
+	return r
+}
+
+// NewDiffReport builds a report of the changes between two entries,
+// as found by DiffEntries, in either order; each task records how it
+// changed.
+func (ws *Workspace) NewDiffReport(from, to uint64) *Report {
+	if from > to {
+		from, to = to, from
+	}
+
+	r := ws.newDayReport("diff", ws.EntryDay(from), ws.EntryDay(to))
+
+	d := ws.DiffEntries(from, to)
+	carried := ws.CarryCounts()
+	for _, change := range []struct {
+		name  string
+		tasks []*Task
+	}{
+		{"added", d.Added},
+		{"completed", d.Completed},
+		{"carried", d.Carried},
+		{"dropped", d.Dropped},
+	} {
+		for _, task := range change.tasks {
+			r.add(task, carried[task.ID], change.name)
+		}
+	}
+
+	r.Count = len(r.Tasks)
+	return r
+}
+
+// WriteJSON writes the report as a single indented JSON object.
+func (r *Report) WriteJSON(w io.Writer) error {
+	out, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	out = append(out, '\n')
+	_, err = w.Write(out)
+	return err
+}
+
+// WriteNDJSON writes the report as newline-delimited JSON: a header
+// record followed by one record per task. Each record has a "type"
+// field, "header" or "task", along with the fields of the ReportHeader
+// or TaskRecord.
+func (r *Report) WriteNDJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	err := enc.Encode(struct {
+		Type string `json:"type"`
+		ReportHeader
+	}{"header", r.ReportHeader})
+	if err != nil {
+		return err
+	}
+
+	for i := range r.Tasks {
+		err = enc.Encode(struct {
+			Type string `json:"type"`
+			TaskRecord
+		}{"task", r.Tasks[i]})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write writes the report in the JSON or NDJSON format.
+func (r *Report) Write(w io.Writer, format string) error {
+	if format == FormatNDJSON {
+		return r.WriteNDJSON(w)
+	}
+	return r.WriteJSON(w)
+}
+
+// Output formats supported by the listing tools.
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatNDJSON   = "ndjson"
+	FormatHTML     = "html"
+)
+
+// CheckFormat returns an error if format isn't a known output format.
+func CheckFormat(format string) error {
+	switch format {
+	case FormatText, FormatMarkdown, FormatJSON, FormatNDJSON, FormatHTML:
+		return nil
+	}
+
+	return errors.New("workspace: unknown output format " + format)
+}
+
+// FormatUsage describes the output formats, for usage messages.
+var FormatUsage = `Output formats:
+
+    text			The default human-readable listing.
+    markdown			A markdown document; the same as -m.
+    json			A single JSON object containing the report
+				header and a "tasks" array.
+    ndjson			Newline-delimited JSON: a header record
+				followed by one task per line, each with
+				a "type" of "header" or "task".
+    html			A self-contained HTML page, suitable for
+				emailing; see -group.
+
+The JSON schema is described in the README.
+`
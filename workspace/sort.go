@@ -0,0 +1,227 @@
+package workspace
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A SortField identifies a task field used to order tasks.
+type SortField uint8
+
+const (
+	// SortPriority orders tasks by priority.
+	SortPriority SortField = iota + 1
+
+	// SortCreated orders tasks by their creation time.
+	SortCreated
+
+	// SortFinished orders tasks by their completion time.
+	SortFinished
+
+	// SortDue orders tasks by their due date; tasks without a due
+	// date sort after those with one.
+	SortDue
+
+	// SortTitle orders tasks by title, ignoring case.
+	SortTitle
+
+	// SortOrder orders tasks by their position in the sorter's
+	// custom order, usually the order of an entry's tasks.
+	SortOrder
+)
+
+var sortFieldNames = map[string]SortField{
+	"priority": SortPriority,
+	"created":  SortCreated,
+	"finished": SortFinished,
+	"due":      SortDue,
+	"title":    SortTitle,
+	"order":    SortOrder,
+}
+
+// A SortKey is a single field to sort on, and its direction.
+type SortKey struct {
+	Field      SortField
+	Descending bool
+}
+
+// A Sorter orders tasks by a list of keys; ties on every key are
+// broken by task ID so that the order is always deterministic.
+type Sorter struct {
+	Keys []SortKey
+
+	// Order is the custom ordering used by SortOrder. Tasks that
+	// don't appear in it sort after those that do.
+	Order []uint64
+}
+
+// DefaultSort is the sort specification used when none is given on
+// the command line or in the configuration file.
+const DefaultSort = "created"
+
+// EntrySort is the sort specification used for the day's list of
+// tasks when none is given on the command line, so that the list
+// follows the order set with util37-order.
+const EntrySort = "order"
+
+// ParseSort parses a comma-separated list of sort keys, such as
+// "-priority,created". A leading '-' sorts that key in descending
+// order.
+func ParseSort(spec string) (*Sorter, error) {
+	s := &Sorter{}
+	for _, name := range Tokenize(spec, ",") {
+		var key SortKey
+		if strings.HasPrefix(name, "-") {
+			key.Descending = true
+			name = name[1:]
+		}
+
+		field, ok := sortFieldNames[strings.ToLower(name)]
+		if !ok {
+			return nil, errors.New("workspace: unknown sort key " + name)
+		}
+		key.Field = field
+		s.Keys = append(s.Keys, key)
+	}
+
+	return s, nil
+}
+
+// LoadSort returns the sorter for spec; if spec is empty, the default
+// from the configuration file is used, falling back to DefaultSort.
+func LoadSort(spec string) (*Sorter, error) {
+	if spec == "" {
+		cfg, err := ReadConfig()
+		if err != nil {
+			return nil, err
+		}
+		spec = cfg.Sort
+	}
+
+	if spec == "" {
+		spec = DefaultSort
+	}
+
+	return ParseSort(spec)
+}
+
+// LoadEntrySort returns the sorter for listing the day's tasks; if
+// spec is empty, EntrySort is used.
+func LoadEntrySort(spec string) (*Sorter, error) {
+	if spec == "" {
+		spec = EntrySort
+	}
+
+	return ParseSort(spec)
+}
+
+func compareTimes(t1, t2 time.Time) int {
+	switch {
+	case t1.Equal(t2):
+		return 0
+	case t1.IsZero():
+		return 1
+	case t2.IsZero():
+		return -1
+	case t1.Before(t2):
+		return -1
+	default:
+		return 1
+	}
+}
+
+func compareInts(i1, i2 int) int {
+	switch {
+	case i1 < i2:
+		return -1
+	case i1 > i2:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (s *Sorter) compare(key SortKey, t1, t2 *Task, order map[uint64]int) int {
+	var c int
+	switch key.Field {
+	case SortPriority:
+		c = compareInts(int(t1.Priority), int(t2.Priority))
+	case SortCreated:
+		c = compareTimes(t1.Created, t2.Created)
+	case SortFinished:
+		c = compareTimes(t1.Finished, t2.Finished)
+	case SortDue:
+		c = compareTimes(t1.Due, t2.Due)
+	case SortTitle:
+		c = strings.Compare(strings.ToLower(t1.Title),
+			strings.ToLower(t2.Title))
+	case SortOrder:
+		p1, ok1 := order[t1.ID]
+		p2, ok2 := order[t2.ID]
+		switch {
+		case ok1 && ok2:
+			c = compareInts(p1, p2)
+		case ok1:
+			c = -1
+		case ok2:
+			c = 1
+		}
+	}
+
+	if key.Descending {
+		c = -c
+	}
+	return c
+}
+
+// Sort returns the tasks in ts ordered by the sorter's keys.
+func (s *Sorter) Sort(ts TaskSet) []*Task {
+	var tasks = make([]*Task, 0, len(ts))
+	for _, task := range ts {
+		tasks = append(tasks, task)
+	}
+
+	var order = make(map[uint64]int, len(s.Order))
+	for i, id := range s.Order {
+		order[id] = i
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		for _, key := range s.Keys {
+			c := s.compare(key, tasks[i], tasks[j], order)
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+
+	return tasks
+}
+
+// WithOrder returns a copy of the sorter using order as its custom
+// ordering.
+func (s *Sorter) WithOrder(order []uint64) *Sorter {
+	return &Sorter{Keys: s.Keys, Order: order}
+}
+
+// SortUsage describes the sort specification, for usage messages.
+var SortUsage = `Sort keys:
+
+Tasks are sorted by a comma-separated list of keys; prefix a key with
+'-' to reverse it. Ties are broken by task ID, which follows the order
+tasks were added.
+
+    priority			Task priority, lowest first.
+    created			Creation date.
+    finished			Completion date.
+    due				Due date; tasks without one sort last.
+    title			Task title, ignoring case.
+    order			The order tasks appear in the day's entry.
+
+The default is "` + DefaultSort + `", which may be changed with the "Sort"
+key in ~/.config/util37/config; the day's list of tasks is sorted by
+"` + EntrySort + `" unless keys are given.
+`
@@ -0,0 +1,52 @@
+package workspace
+
+// CarryCounts returns the number of times each task has been carried
+// over from one day's list to the next, which is one less than the
+// number of entries it appears in. Tasks that have only appeared on
+// one list are omitted.
+func (ws *Workspace) CarryCounts() map[uint64]int {
+	var counts = map[uint64]int{}
+	for _, e := range ws.Entries {
+		for _, id := range e.Tasks {
+			counts[id]++
+		}
+	}
+
+	for id, n := range counts {
+		if n <= 1 {
+			delete(counts, id)
+		} else {
+			counts[id] = n - 1
+		}
+	}
+	return counts
+}
+
+// StaleFilter returns a filter selecting the unfinished tasks that
+// have been carried over more than n times (or at least n times, if
+// inclusive is true). Snoozed tasks aren't stale until their wait
+// ends.
+func (ws *Workspace) StaleFilter(n int, inclusive bool) Filter {
+	counts := ws.CarryCounts()
+	today := ws.Today()
+	return func(ts TaskSet) TaskSet {
+		var tasks = TaskSet{}
+		for id, task := range ts {
+			if task.Done || task.Waiting(today) {
+				continue
+			}
+
+			carried := counts[id]
+			if carried > n || (inclusive && carried == n) {
+				tasks[id] = task
+			}
+		}
+		return tasks
+	}
+}
+
+// Stale returns the unfinished tasks that have been carried over at
+// least n times.
+func (ws *Workspace) Stale(n int) TaskSet {
+	return ws.StaleFilter(n, true)(ws.Tasks)
+}
@@ -0,0 +1,170 @@
+package workspace
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// BlockedTag is the tag that marks a task as blocked by default.
+const BlockedTag = "blocked"
+
+var blockedRegexp = regexp.MustCompile(`(?i)\bblocked\b`)
+
+// Blocked returns true if the task has the tag, or a tag beneath it,
+// or says that it's blocked in its title or notes.
+func (t *Task) Blocked(tag string) bool {
+	for _, tt := range t.Tags {
+		if TagWithin(tt, tag) {
+			return true
+		}
+	}
+
+	if blockedRegexp.MatchString(t.Title) {
+		return true
+	}
+
+	for _, note := range t.Notes {
+		if blockedRegexp.MatchString(note) {
+			return true
+		}
+	}
+	return false
+}
+
+// A Standup summarises a workspace for a daily standup meeting.
+type Standup struct {
+	Workspace string
+
+	// Date is the day of the standup, and Since the start of the
+	// previous day's list.
+	Date, Since time.Time
+
+	// Done contains the tasks completed since the previous day's
+	// list was started, in the order they were completed.
+	Done []*Task
+
+	// Planned contains today's unfinished tasks, in the day's
+	// order, and Blocked those that are blocked.
+	Planned []*Task
+	Blocked []*Task
+
+	// Carried gives the number of times each task has been carried
+	// over from one day's list to the next.
+	Carried map[uint64]int
+}
+
+// Standup builds a standup summary from the previous entry and
+// today's, creating today's entry if needed. Tasks with the blocked
+// tag, or that say they're blocked, are listed as blockers.
+func (ws *Workspace) Standup(blockedTag string) *Standup {
+	id := ws.NewEntry()
+	today := ws.Today()
+
+	s := &Standup{
+		Workspace: ws.Name,
+		Date:      today,
+		Since:     today.AddDate(0, 0, -1),
+		Carried:   ws.CarryCounts(),
+	}
+
+	if prev, ok := ws.AdjacentEntry(id, -1); ok {
+		s.Since = ws.EntryDay(prev)
+	}
+
+	start := ws.DayBegin(s.Since)
+	end := ws.DayBegin(today)
+	for _, task := range ws.Tasks {
+		if task.Done && !task.Cancelled &&
+			!task.Finished.Before(start) && task.Finished.Before(end) {
+			s.Done = append(s.Done, task)
+		}
+	}
+	sort.Slice(s.Done, func(i, j int) bool {
+		return s.Done[i].Finished.Before(s.Done[j].Finished)
+	})
+
+	for _, tid := range ws.Entries[id].Tasks {
+		task := ws.Tasks[tid]
+		if task == nil || task.Done {
+			continue
+		}
+
+		if task.Blocked(blockedTag) {
+			s.Blocked = append(s.Blocked, task)
+		} else {
+			s.Planned = append(s.Planned, task)
+		}
+	}
+
+	return s
+}
+
+// A StandupRecord is the stable JSON representation of a standup.
+//
+//	workspace  the workspace name
+//	date       the day of the standup
+//	since      the start of the previous day's list
+//	done       the tasks completed since then
+//	planned    today's unfinished tasks that aren't blocked
+//	blocked    today's blocked tasks
+type StandupRecord struct {
+	Workspace string       `json:"workspace"`
+	Date      time.Time    `json:"date"`
+	Since     time.Time    `json:"since"`
+	Done      []TaskRecord `json:"done"`
+	Planned   []TaskRecord `json:"planned"`
+	Blocked   []TaskRecord `json:"blocked"`
+}
+
+func (s *Standup) records(tasks []*Task) []TaskRecord {
+	var rs = make([]TaskRecord, 0, len(tasks))
+	for _, task := range tasks {
+		r := task.Record()
+		r.Carried = s.Carried[task.ID]
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+// Record returns the standup's JSON representation.
+func (s *Standup) Record() StandupRecord {
+	return StandupRecord{
+		Workspace: s.Workspace,
+		Date:      s.Date,
+		Since:     s.Since,
+		Done:      s.records(s.Done),
+		Planned:   s.records(s.Planned),
+		Blocked:   s.records(s.Blocked),
+	}
+}
+
+// WriteStandupJSON writes the standups for one or more workspaces as
+// a single JSON object, with a "standups" array holding one
+// StandupRecord for each workspace.
+func WriteStandupJSON(w io.Writer, standups []*Standup) error {
+	r := struct {
+		Version   int             `json:"version"`
+		Generated time.Time       `json:"generated"`
+		Standups  []StandupRecord `json:"standups"`
+	}{
+		Version:   ReportVersion,
+		Generated: time.Now(),
+		Standups:  make([]StandupRecord, 0, len(standups)),
+	}
+
+	for _, s := range standups {
+		r.Standups = append(r.Standups, s.Record())
+	}
+
+	out, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	out = append(out, '\n')
+	_, err = w.Write(out)
+	return err
+}
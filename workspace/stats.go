@@ -0,0 +1,216 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A Bucket counts the tasks created and completed in one period.
+type Bucket struct {
+	Start     time.Time `json:"start"`
+	Created   int       `json:"created"`
+	Completed int       `json:"completed"`
+}
+
+// An AgeBucket counts the open tasks whose age falls in a range.
+type AgeBucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// A Breakdown summarises the tasks sharing a tag or priority.
+type Breakdown struct {
+	Name   string        `json:"name"`
+	Open   int           `json:"open"`
+	Done   int           `json:"done"`
+	Median time.Duration `json:"median_ns"`
+}
+
+// Stats contains productivity statistics for a set of tasks.
+type Stats struct {
+	// Period is the length of each bucket, "day" or "week".
+	Period  string   `json:"period"`
+	Buckets []Bucket `json:"buckets"`
+
+	Total int `json:"total"`
+	Open  int `json:"open"`
+	Done  int `json:"done"`
+
+	// Median and P90 are the median and 90th percentile times
+	// taken to complete a task.
+	Median time.Duration `json:"median_ns"`
+	P90    time.Duration `json:"p90_ns"`
+
+	// Ages is the distribution of the ages of open tasks.
+	Ages []AgeBucket `json:"ages"`
+
+	ByTag      []Breakdown `json:"by_tag"`
+	ByPriority []Breakdown `json:"by_priority"`
+}
+
+var ageLimits = []struct {
+	label string
+	limit time.Duration
+}{
+	{"under a day", DurationDay},
+	{"1 day to 1 week", DurationWeek},
+	{"1 to 4 weeks", DurationMonth},
+	{"1 to 3 months", 3 * DurationMonth},
+	{"over 3 months", 0},
+}
+
+// Percentile returns the pth percentile of the durations, using the
+// nearest-rank method. The durations must be sorted.
+func Percentile(ds []time.Duration, p int) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+
+	rank := (p*len(ds) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return ds[rank-1]
+}
+
+func cycleTimes(tasks []*Task) []time.Duration {
+	var ds []time.Duration
+	for _, task := range tasks {
+		if task.Done {
+			ds = append(ds, task.Finished.Sub(task.Created))
+		}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	return ds
+}
+
+func breakdown(name string, tasks []*Task) Breakdown {
+	b := Breakdown{Name: name}
+	for _, task := range tasks {
+		if task.Done {
+			b.Done++
+		} else {
+			b.Open++
+		}
+	}
+	b.Median = Percentile(cycleTimes(tasks), 50)
+	return b
+}
+
+// PeriodStart returns the start of the period containing t; weeks
+// start on Monday.
+func PeriodStart(t time.Time, period string) time.Time {
+	t = Day(t)
+	if period == "week" {
+		offset := (int(t.Weekday()) + 6) % 7
+		t = t.AddDate(0, 0, -offset)
+	}
+	return t
+}
+
+func nextPeriod(t time.Time, period string) time.Time {
+	if period == "week" {
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// ComputeStats computes statistics for the tasks, with creation and
+// completion counts bucketed by period ("day" or "week") from start
+// to end inclusive. Times are counted in the workspace day they fall
+// in.
+func (ws *Workspace) ComputeStats(ts TaskSet, period string, start, end time.Time) (*Stats, error) {
+	if period != "day" && period != "week" {
+		return nil, errors.New("workspace: unknown period " + period)
+	}
+
+	tasks := ts.Sort()
+	s := &Stats{Period: period, Total: len(tasks)}
+
+	start = PeriodStart(start, period)
+	end = PeriodStart(end, period)
+	for t := start; !t.After(end); t = nextPeriod(t, period) {
+		s.Buckets = append(s.Buckets, Bucket{Start: t})
+	}
+
+	index := func(t time.Time) int {
+		p := PeriodStart(ws.DayOf(t), period)
+		for i := range s.Buckets {
+			if s.Buckets[i].Start.Equal(p) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, label := range ageLimits {
+		s.Ages = append(s.Ages, AgeBucket{Label: label.label})
+	}
+
+	now := time.Now()
+	var byTag = map[string][]*Task{}
+	var byPriority = map[Priority][]*Task{}
+	for _, task := range tasks {
+		if i := index(task.Created); i >= 0 {
+			s.Buckets[i].Created++
+		}
+
+		if task.Done {
+			s.Done++
+			if i := index(task.Finished); i >= 0 {
+				s.Buckets[i].Completed++
+			}
+		} else {
+			s.Open++
+			age := now.Sub(task.Created)
+			for i, label := range ageLimits {
+				if label.limit == 0 || age < label.limit {
+					s.Ages[i].Count++
+					break
+				}
+			}
+		}
+
+		for _, tag := range task.Tags {
+			byTag[tag] = append(byTag[tag], task)
+		}
+		byPriority[task.Priority] = append(byPriority[task.Priority], task)
+	}
+
+	ds := cycleTimes(tasks)
+	s.Median = Percentile(ds, 50)
+	s.P90 = Percentile(ds, 90)
+
+	var tags = make([]string, 0, len(byTag))
+	for tag := range byTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		s.ByTag = append(s.ByTag, breakdown(tag, byTag[tag]))
+	}
+
+	for _, pri := range priorityOrder {
+		if len(byPriority[pri]) > 0 {
+			s.ByPriority = append(s.ByPriority,
+				breakdown(priorityNames[pri], byPriority[pri]))
+		}
+	}
+
+	return s, nil
+}
+
+// FormatDuration returns a short, human-readable form of a duration,
+// such as "45m", "3.5h" or "2.0d".
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%.0fm", d.Minutes())
+	case d < DurationDay:
+		return fmt.Sprintf("%.1fh", d.Hours())
+	default:
+		return fmt.Sprintf("%.1fd", d.Hours()/24)
+	}
+}
@@ -0,0 +1,336 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// TagSeparator separates the levels of a hierarchical tag, such as
+// "client/acme/billing".
+const TagSeparator = "/"
+
+// TagWithin returns true if tag is either parent or one of its
+// descendants.
+func TagWithin(tag, parent string) bool {
+	return tag == parent || strings.HasPrefix(tag, parent+TagSeparator)
+}
+
+// A TagNode is one level in the tag hierarchy.
+type TagNode struct {
+	// Name is the last component of the tag.
+	Name string
+
+	// Tag is the full tag name.
+	Tag string
+
+	// Count is the number of distinct tasks with this tag or any
+	// of its descendants.
+	Count int
+
+	// Children contains the next level of the hierarchy, sorted by
+	// name.
+	Children []*TagNode
+
+	tasks map[uint64]bool
+}
+
+func (n *TagNode) child(name string) *TagNode {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	tag := name
+	if n.Tag != "" {
+		tag = n.Tag + TagSeparator + name
+	}
+
+	c := &TagNode{Name: name, Tag: tag, tasks: map[uint64]bool{}}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+func (n *TagNode) finish() {
+	n.Count = len(n.tasks)
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, c := range n.Children {
+		c.finish()
+	}
+}
+
+// TagTree builds the tag hierarchy from the workspace's tag index. The
+// root node has no name; its count is the number of tagged tasks.
+func (ws *Workspace) TagTree() *TagNode {
+	root := &TagNode{tasks: map[uint64]bool{}}
+
+	for tag, ids := range ws.Tags {
+		node := root
+		for _, name := range Tokenize(tag, TagSeparator) {
+			node = node.child(name)
+			for _, id := range ids {
+				node.tasks[id] = true
+			}
+		}
+		for _, id := range ids {
+			root.tasks[id] = true
+		}
+	}
+
+	root.finish()
+	return root
+}
+
+// Write prints the tree beneath the node, indenting each level.
+func (n *TagNode) Write(w io.Writer) {
+	n.write(w, "")
+}
+
+func (n *TagNode) write(w io.Writer, indent string) {
+	for _, c := range n.Children {
+		fmt.Fprintf(w, "%s%s (%d)\n", indent, c.Name, c.Count)
+		c.write(w, indent+"    ")
+	}
+}
+
+func removeTag(tags []string, tag string) []string {
+	var out = make([]string, 0, len(tags))
+	for i := range tags {
+		if tags[i] != tag {
+			out = append(out, tags[i])
+		}
+	}
+	return out
+}
+
+func removeID(ids []uint64, id uint64) []uint64 {
+	var out = make([]uint64, 0, len(ids))
+	for i := range ids {
+		if ids[i] != id {
+			out = append(out, ids[i])
+		}
+	}
+	return out
+}
+
+// Untag removes a tag from the specified task, returning false if the
+// task doesn't exist or doesn't have the tag.
+func (ws *Workspace) Untag(id uint64, tag string) bool {
+	task, ok := ws.Tasks[id]
+	if !ok || !contains(tag, task.Tags) {
+		return false
+	}
+
+	task.Tags = removeTag(task.Tags, tag)
+	ids := removeID(ws.Tags[tag], id)
+	if len(ids) == 0 {
+		delete(ws.Tags, tag)
+	} else {
+		ws.Tags[tag] = ids
+	}
+
+	return true
+}
+
+// retag moves every task tagged with from, or one of its descendants,
+// to the corresponding tag beneath to. It returns the number of tasks
+// changed.
+func (ws *Workspace) retag(from, to string) int {
+	var changed = map[uint64]bool{}
+
+	for id, task := range ws.Tasks {
+		for _, tag := range task.Tags {
+			if !TagWithin(tag, from) {
+				continue
+			}
+
+			ws.Untag(id, tag)
+			ws.Tag(id, to+tag[len(from):])
+			changed[id] = true
+		}
+	}
+
+	return len(changed)
+}
+
+func (ws *Workspace) hasTag(tag string) bool {
+	for _, task := range ws.Tasks {
+		for i := range task.Tags {
+			if TagWithin(task.Tags[i], tag) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func checkTagNames(from, to string) error {
+	if from == "" || to == "" {
+		return errors.New("workspace: empty tag name")
+	}
+
+	if from == to {
+		return errors.New("workspace: tag " + from + " is unchanged")
+	}
+
+	if TagWithin(to, from) {
+		return errors.New("workspace: can't move tag " + from +
+			" beneath itself")
+	}
+
+	return nil
+}
+
+// RenameTag renames a tag, and all the tags beneath it, on every task
+// and in the tag index. Renaming to a tag that is already in use is an
+// error; use MergeTag to combine tags. It returns the number of tasks
+// changed.
+func (ws *Workspace) RenameTag(from, to string) (int, error) {
+	from = strings.Trim(strings.TrimSpace(from), TagSeparator)
+	to = strings.Trim(strings.TrimSpace(to), TagSeparator)
+	if err := checkTagNames(from, to); err != nil {
+		return 0, err
+	}
+
+	if !ws.hasTag(from) {
+		return 0, errors.New("workspace: no tasks are tagged " + from)
+	}
+
+	if ws.hasTag(to) {
+		return 0, errors.New("workspace: tag " + to + " already exists")
+	}
+
+	return ws.retag(from, to), nil
+}
+
+// MergeTag merges a tag, and all the tags beneath it, into another
+// tag, which may already be in use. It returns the number of tasks
+// changed.
+func (ws *Workspace) MergeTag(from, into string) (int, error) {
+	from = strings.Trim(strings.TrimSpace(from), TagSeparator)
+	into = strings.Trim(strings.TrimSpace(into), TagSeparator)
+	if err := checkTagNames(from, into); err != nil {
+		return 0, err
+	}
+
+	if !ws.hasTag(from) {
+		return 0, errors.New("workspace: no tasks are tagged " + from)
+	}
+
+	return ws.retag(from, into), nil
+}
+
+// DeleteTag removes a tag, and all the tags beneath it, from every
+// task. It returns the number of tasks changed.
+func (ws *Workspace) DeleteTag(tag string) int {
+	tag = strings.Trim(strings.TrimSpace(tag), TagSeparator)
+
+	var changed = map[uint64]bool{}
+	for id, task := range ws.Tasks {
+		for _, t := range task.Tags {
+			if TagWithin(t, tag) {
+				ws.Untag(id, t)
+				changed[id] = true
+			}
+		}
+	}
+
+	return len(changed)
+}
+
+// A Problem describes an inconsistency found when checking a
+// workspace.
+type Problem struct {
+	// Tag is the tag involved, if any.
+	Tag string
+
+	// ID is the task involved.
+	ID uint64
+
+	// Description explains the problem.
+	Description string
+}
+
+// String provides a default representation for a problem.
+func (p Problem) String() string {
+	if p.Tag == "" {
+		return fmt.Sprintf("task %d: %s", p.ID, p.Description)
+	}
+	return fmt.Sprintf("tag %s, task %d: %s", p.Tag, p.ID, p.Description)
+}
+
+// CheckTags compares the tag index against the tags on each task,
+// returning any inconsistencies.
+func (ws *Workspace) CheckTags() []Problem {
+	var problems []Problem
+
+	var tags = make([]string, 0, len(ws.Tags))
+	for tag := range ws.Tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		seen := map[uint64]bool{}
+		for _, id := range ws.Tags[tag] {
+			task, ok := ws.Tasks[id]
+			switch {
+			case seen[id]:
+				problems = append(problems, Problem{tag, id,
+					"listed more than once in the tag index"})
+			case !ok:
+				problems = append(problems, Problem{tag, id,
+					"orphaned: the task doesn't exist"})
+			case !contains(tag, task.Tags):
+				problems = append(problems, Problem{tag, id,
+					"orphaned: the task doesn't have the tag"})
+			}
+			seen[id] = true
+		}
+	}
+
+	for _, task := range ws.Tasks.Sort() {
+		for _, tag := range task.Tags {
+			found := false
+			for _, id := range ws.Tags[tag] {
+				if id == task.ID {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				problems = append(problems, Problem{tag, task.ID,
+					"missing from the tag index"})
+			}
+		}
+	}
+
+	return problems
+}
+
+// RebuildTags discards the tag index and rebuilds it from the tags on
+// each task.
+func (ws *Workspace) RebuildTags() {
+	ws.Tags = map[string][]uint64{}
+	for _, task := range ws.Tasks.Sort() {
+		var tags []string
+		for _, tag := range task.Tags {
+			if !contains(tag, tags) {
+				tags = append(tags, tag)
+			}
+		}
+		sort.Strings(tags)
+		task.Tags = tags
+
+		for _, tag := range tags {
+			ws.Tags[tag] = append(ws.Tags[tag], task.ID)
+		}
+	}
+}
@@ -35,6 +35,12 @@ var priorityStrings = map[Priority]string{
 	PriorityUrgent:  "!",
 }
 
+// priorityOrder lists the priorities from most to least important.
+var priorityOrder = []Priority{
+	PriorityUrgent, PriorityHigh, PriorityNormal, PriorityLow,
+	PriorityUnknown,
+}
+
 // String provides a string representation for the Priority type.
 func (pri Priority) String() string {
 	s, ok := priorityStrings[pri]
@@ -74,22 +80,47 @@ type Task struct {
 	ID                uint64
 	Done              bool
 	Created, Finished time.Time
+	Due               time.Time
 	Title             string
 	Notes             []string
 	Tags              []string
 	Priority          Priority
+
+	// Cancelled is set for tasks that were closed without being
+	// completed; Done is also set, with Finished recording when the
+	// task was cancelled.
+	Cancelled bool `json:",omitempty"`
+
+	// Wait is the day before which the task won't be added to the
+	// day's list of tasks. It's cleared when the wait ends and the
+	// task is put on the day's list.
+	Wait time.Time
+
+	// UID identifies the task in another tool it was imported
+	// from, so that importing it again updates the task.
+	UID string `json:",omitempty"`
+
+	// Parent is the ID of the task this is a subtask of, for tasks
+	// imported from nested checklists.
+	Parent uint64 `json:",omitempty"`
 }
 
 // String provides a default representation for a task.
 func (t *Task) String() string {
 	marker := " "
-	if t.Done {
+	if t.Cancelled {
+		marker = "-"
+	} else if t.Done {
 		marker = "X"
 	}
 
 	endDate := ""
-	if t.Done {
+	if t.Cancelled {
+		endDate = fmt.Sprintf(", cancelled %s", t.Finished.Format(DateFormat))
+	} else if t.Done {
 		endDate = fmt.Sprintf(", completed %s", t.Finished.Format(DateFormat))
+	} else if !t.Wait.IsZero() {
+		endDate = fmt.Sprintf(", waiting until %s", t.Wait.Format(DateFormat))
 	}
 
 	return fmt.Sprintf("[%s] %s (%s) - %s%s", marker, t.Title, t.Priority,
@@ -123,6 +154,20 @@ func (t *Task) MarkDone() {
 	t.Finished = time.Now()
 }
 
+// MarkCancelled closes a task without completing it, marking the
+// cancellation time as now.
+func (t *Task) MarkCancelled() {
+	t.MarkDone()
+	t.Cancelled = true
+}
+
+// Waiting returns true if the task shouldn't be added to the list for
+// day, a workspace day such as one returned by Workspace.Today. Days
+// are compared by their dates.
+func (t *Task) Waiting(day time.Time) bool {
+	return !t.Done && Day(t.Wait).After(Day(day))
+}
+
 // TagString returns a string containing all the tags in the task.
 func (t *Task) TagString() string {
 	return strings.Join(t.Tags, ", ")
@@ -153,13 +198,19 @@ func (ts TaskSet) FilterPriority(pri Priority) TaskSet {
 	return tasks
 }
 
-// FilterTag returns all tasks with the given tag.
+// FilterTag returns all tasks with the given tag. Tags are
+// hierarchical, with levels separated by slashes: filtering on
+// "client/acme" also selects tasks tagged "client/acme/billing".
 func (ts TaskSet) FilterTag(tag string) TaskSet {
 	var tasks = TaskSet{}
 
+	tag = strings.TrimSuffix(tag, TagSeparator)
 	for id, task := range ts {
-		if contains(tag, task.Tags) {
-			tasks[id] = task
+		for i := range task.Tags {
+			if TagWithin(task.Tags[i], tag) {
+				tasks[id] = task
+				break
+			}
 		}
 	}
 
@@ -257,17 +308,12 @@ func (ts TaskSet) CreatedRange(start, end time.Time) TaskSet {
 
 // Sort returns a list of the tasks in chronological order.
 func (ts TaskSet) Sort() []*Task {
-	var ids = make([]uint64, 0, len(ts))
-	for id := range ts {
-		ids = append(ids, id)
-	}
-
-	var tasks = make([]*Task, len(ids))
-	for i := range ids {
-		tasks[i] = ts[ids[i]]
-	}
+	return ts.SortBy(&Sorter{Keys: []SortKey{{Field: SortCreated}}})
+}
 
-	return tasks
+// SortBy returns a list of the tasks ordered by the sorter.
+func (ts TaskSet) SortBy(s *Sorter) []*Task {
+	return s.Sort(ts)
 }
 
 // NewTaskID returns a new task identifier.
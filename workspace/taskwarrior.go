@@ -0,0 +1,313 @@
+package workspace
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// twDateFormat is the format of Taskwarrior's dates.
+const twDateFormat = "20060102T150405Z"
+
+type twAnnotation struct {
+	Entry       string `json:"entry"`
+	Description string `json:"description"`
+}
+
+// twTask is a task in Taskwarrior's JSON format. The task's ID is kept
+// in the util37id attribute, which Taskwarrior preserves as an orphaned
+// user-defined attribute.
+type twTask struct {
+	UUID        string         `json:"uuid"`
+	Description string         `json:"description"`
+	Status      string         `json:"status"`
+	Entry       string         `json:"entry"`
+	End         string         `json:"end,omitempty"`
+	Due         string         `json:"due,omitempty"`
+	Wait        string         `json:"wait,omitempty"`
+	Priority    string         `json:"priority,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`
+	Annotations []twAnnotation `json:"annotations,omitempty"`
+	ID          string         `json:"util37id,omitempty"`
+}
+
+// twFields are the Taskwarrior attributes that are converted; id and
+// urgency are computed by Taskwarrior, so they're ignored.
+var twFields = map[string]bool{
+	"uuid": true, "description": true, "status": true, "entry": true,
+	"end": true, "due": true, "wait": true, "priority": true,
+	"tags": true, "annotations": true, "project": true, "util37id": true,
+	"id": true, "urgency": true,
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// taskUUID returns the task's UID if it's a UUID, or a UUID derived
+// from its ID, so that exporting a task again gives the same UUID.
+func taskUUID(t *Task) string {
+	if uuidRegexp.MatchString(t.UID) {
+		return t.UID
+	}
+
+	sum := sha1.Sum([]byte(fmt.Sprintf("util37:%d", t.ID)))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8],
+		sum[8:10], sum[10:16])
+}
+
+func twDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(twDateFormat)
+}
+
+func parseTWDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(twDateFormat, s)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, s)
+	}
+
+	if err != nil {
+		return time.Time{}, errors.New("workspace: invalid Taskwarrior date " + s)
+	}
+	return t.Local(), nil
+}
+
+// WriteTaskwarrior writes the tasks as a JSON array that Taskwarrior
+// can import, returning the fields that couldn't be converted exactly.
+func WriteTaskwarrior(w io.Writer, tasks []*Task) (Unmapped, error) {
+	var unmapped = Unmapped{}
+	var tws = make([]twTask, 0, len(tasks))
+	for _, t := range tasks {
+		tw := twTask{
+			UUID:        taskUUID(t),
+			Description: t.Title,
+			Status:      "pending",
+			Entry:       twDate(t.Created),
+			Due:         twDate(t.Due),
+			Wait:        twDate(t.Wait),
+			Tags:        t.Tags,
+			ID:          strconv.FormatUint(t.ID, 10),
+		}
+
+		if t.Cancelled {
+			tw.Status = "deleted"
+			tw.End = twDate(t.Finished)
+		} else if t.Done {
+			tw.Status = "completed"
+			tw.End = twDate(t.Finished)
+		}
+
+		switch t.Priority {
+		case PriorityUrgent:
+			tw.Priority = "H"
+			unmapped["priority urgent, written as H"]++
+		case PriorityHigh:
+			tw.Priority = "H"
+		case PriorityNormal:
+			tw.Priority = "M"
+		case PriorityLow:
+			tw.Priority = "L"
+		}
+
+		for _, note := range t.Notes {
+			tw.Annotations = append(tw.Annotations, twAnnotation{
+				Entry:       tw.Entry,
+				Description: note,
+			})
+		}
+		if len(t.Notes) > 0 {
+			unmapped["annotation times, set to the task's creation time"]++
+		}
+
+		tws = append(tws, tw)
+	}
+
+	out, err := json.MarshalIndent(tws, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	out = append(out, '\n')
+	_, err = w.Write(out)
+	return unmapped, err
+}
+
+// readTWObjects reads the output of "task export": either a JSON array
+// or, from older versions, one object per line separated by commas.
+func readTWObjects(r io.Reader) ([]map[string]json.RawMessage, error) {
+	in, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	in = bytes.TrimSpace(in)
+	if len(in) == 0 {
+		return nil, nil
+	}
+
+	if in[0] != '[' {
+		in = append(append([]byte("["), bytes.TrimRight(in, ",")...), ']')
+	}
+
+	var objects []map[string]json.RawMessage
+	if err = json.Unmarshal(in, &objects); err != nil {
+		return nil, errors.New("workspace: invalid Taskwarrior export: " + err.Error())
+	}
+	return objects, nil
+}
+
+// ReadTaskwarrior reads tasks exported from Taskwarrior, returning the
+// fields that couldn't be converted. Projects become tags, with
+// Taskwarrior's dots separating levels replaced by slashes; deleted
+// tasks are cancelled; and recurring task templates are skipped, as
+// their instances are exported separately. Times are given in the
+// workspace's time zone.
+func (ws *Workspace) ReadTaskwarrior(r io.Reader) ([]*Task, Unmapped, error) {
+	objects, err := readTWObjects(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tasks []*Task
+	var unmapped = Unmapped{}
+	for _, object := range objects {
+		var tw twTask
+		var project string
+
+		// Decoding the whole object into a twTask picks out the
+		// known fields; the others are counted as unmapped.
+		raw, err := json.Marshal(object)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err = json.Unmarshal(raw, &tw); err != nil {
+			return nil, nil, errors.New("workspace: invalid Taskwarrior task: " + err.Error())
+		}
+
+		if p, ok := object["project"]; ok {
+			if err = json.Unmarshal(p, &project); err != nil {
+				return nil, nil, errors.New("workspace: invalid Taskwarrior project: " + err.Error())
+			}
+		}
+
+		for field := range object {
+			if !twFields[field] {
+				unmapped[field]++
+			}
+		}
+
+		// Notes don't have times; annotations exported from util37
+		// are given the task's entry time, so only other times are
+		// lost.
+		for _, a := range tw.Annotations {
+			if a.Entry != "" && a.Entry != tw.Entry {
+				unmapped["annotation times"]++
+			}
+		}
+
+		if tw.Status == "recurring" {
+			unmapped["recurring task templates, skipped"]++
+			continue
+		}
+
+		t, err := tw.task(ws, project)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if tw.Priority != "" && t.Priority == PriorityUnknown {
+			unmapped["priority "+tw.Priority]++
+			t.Priority = PriorityNormal
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, unmapped, nil
+}
+
+func (tw *twTask) task(ws *Workspace, project string) (*Task, error) {
+	t := &Task{
+		Title: tw.Description,
+		Tags:  tw.Tags,
+		UID:   tw.UUID,
+	}
+
+	var err error
+	if tw.ID != "" {
+		if t.ID, err = strconv.ParseUint(tw.ID, 10, 64); err != nil {
+			return nil, errors.New("workspace: invalid util37id " + tw.ID)
+		}
+
+		// The UUID of an exported task without a UID is derived
+		// from its ID, so there's nothing to keep.
+		if tw.UUID == taskUUID(&Task{ID: t.ID}) {
+			t.UID = ""
+		}
+	}
+
+	for _, field := range []struct {
+		s string
+		t *time.Time
+	}{
+		{tw.Entry, &t.Created},
+		{tw.End, &t.Finished},
+		{tw.Due, &t.Due},
+		{tw.Wait, &t.Wait},
+	} {
+		if *field.t, err = parseTWDate(field.s); err != nil {
+			return nil, err
+		}
+		*field.t = field.t.In(ws.Location())
+	}
+
+	if t.Created.IsZero() {
+		t.Created = time.Now()
+	}
+	if !t.Wait.IsZero() {
+		t.Wait = ws.Date(t.Wait)
+	}
+
+	switch tw.Status {
+	case "completed", "deleted":
+		t.Done = true
+		t.Cancelled = tw.Status == "deleted"
+		if t.Finished.IsZero() {
+			t.Finished = time.Now()
+		}
+	}
+
+	switch tw.Priority {
+	case "H":
+		t.Priority = PriorityHigh
+	case "M", "":
+		t.Priority = PriorityNormal
+	case "L":
+		t.Priority = PriorityLow
+	}
+
+	if project != "" {
+		t.Tags = append(t.Tags, strings.Replace(project, ".", TagSeparator, -1))
+	}
+
+	for _, a := range tw.Annotations {
+		t.Notes = append(t.Notes, a.Description)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,172 @@
+package workspace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is passed to output templates.
+type TemplateData struct {
+	// Workspace is the name of the workspace.
+	Workspace string
+
+	// Report is the kind of report, e.g. "today" or "review".
+	Report string
+
+	// Date is the date the report covers; for review reports, it
+	// is the date the report was generated.
+	Date time.Time
+
+	// Range describes the time range selected by the query.
+	Range string
+
+	// Long is true if annotations should be shown.
+	Long bool
+
+	// Count is the total number of tasks in Focus and Tasks.
+	Count int
+
+	// Focus contains the day's focus tasks, if any.
+	Focus []*Task
+
+	// Tasks contains the remaining tasks.
+	Tasks []*Task
+
+	// Carried gives the number of times each task has been carried
+	// over from one day's list to the next; tasks that haven't been
+	// carried over are omitted.
+	Carried map[uint64]int
+}
+
+// TemplateFuncs are the helper functions available to output
+// templates.
+var TemplateFuncs = template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Format(DateFormat)
+	},
+	"datefmt": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"today": Today,
+	"wrap":  Wrap,
+	"priority": func(pri Priority) string {
+		return priorityNames[pri]
+	},
+	"tags": func(t *Task) string {
+		return t.TagString()
+	},
+	"join": func(ss []string, sep string) string {
+		return strings.Join(ss, sep)
+	},
+}
+
+var builtinTemplates = map[string]string{
+	"today." + FormatText: `TODO {{date .Date}} ({{.Count}} tasks):
+{{range .Focus}}	* {{.}}{{with index $.Carried .ID}} [carried {{.}}]{{end}}
+{{if $.Long}}{{if .Tags}}		Tags: {{tags .}}
+{{end}}{{range .Notes}}{{wrap (print "+ " .) "\t\t" 72}}
+{{end}}{{end}}{{end}}{{range .Tasks}}	 {{.}}{{with index $.Carried .ID}} [carried {{.}}]{{end}}
+{{if $.Long}}{{if .Tags}}		Tags: {{tags .}}
+{{end}}{{range .Notes}}{{wrap (print "+ " .) "\t\t" 72}}
+{{end}}{{end}}{{end}}`,
+
+	"today." + FormatMarkdown: `## TODO {{date .Date}} ({{.Count}} tasks)
+{{if .Focus}}### Focus
+{{range .Focus}}#### {{.}}{{with index $.Carried .ID}} [carried {{.}}]{{end}}
+{{if $.Long}}{{range .Notes}}{{wrap (print "+ " .) "" 72}}
+{{end}}{{end}}{{end}}### Other tasks
+{{end}}{{range .Tasks}}#### {{.}}{{with index $.Carried .ID}} [carried {{.}}]{{end}}
+{{if $.Long}}{{range .Notes}}{{wrap (print "+ " .) "" 72}}
+{{end}}{{end}}{{end}}`,
+
+	"review." + FormatText: `Completed tasks finished {{.Range}}
+{{range .Tasks}}{{.}}
+{{if $.Long}}	Completion time: {{.TimeTaken}}
+{{if .Tags}}	Tags: {{tags .}}
+{{end}}{{range .Notes}}{{wrap (print "+ " .) "\t" 72}}
+{{end}}{{end}}{{else}}No tasks found.
+{{end}}`,
+
+	"review." + FormatMarkdown: `## Completed tasks finished {{.Range}}
+{{range .Tasks}}#### {{.}}
+{{if $.Long}}+ Completed in {{.TimeTaken}}
+{{range .Notes}}{{wrap (print "+ " .) "" 72}}
+{{end}}{{end}}{{else}}No tasks found.
+{{end}}`,
+
+	"standup." + FormatText: `Standup {{date .Date}} ({{.Workspace}})
+Since {{date .Since}}:
+{{range .Done}}	 {{.}}
+{{else}}	Nothing completed.
+{{end}}Today:
+{{range .Planned}}	 {{.}}
+{{else}}	Nothing planned.
+{{end}}Blockers:
+{{range .Blocked}}	 {{.}}
+{{range .Notes}}{{wrap (print "+ " .) "\t\t" 72}}{{end}}{{else}}	None.
+{{end}}`,
+
+	"standup." + FormatMarkdown: `## Standup {{date .Date}} ({{.Workspace}})
+### Since {{date .Since}}
+{{range .Done}}- {{.Title}}
+{{else}}- Nothing completed.
+{{end}}### Today
+{{range .Planned}}- {{.Title}}
+{{else}}- Nothing planned.
+{{end}}### Blockers
+{{range .Blocked}}- {{.Title}}
+{{range .Notes}}{{wrap (print "+ " .) "  " 72}}{{end}}{{else}}- None.
+{{end}}`,
+}
+
+// NewTemplate parses an output template, making TemplateFuncs
+// available to it.
+func NewTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(TemplateFuncs).Parse(text)
+}
+
+// TemplateDir returns the directory that user templates are read from.
+func TemplateDir() string {
+	basePath := os.Getenv("HOME")
+	return filepath.Join(basePath, ".config", "util37", "templates")
+}
+
+// LoadTemplate finds the template for a report in the given format
+// ("text" or "markdown"). If path is non-empty, the template is read
+// from that file. Otherwise, the first of these that exists is used:
+//
+//	~/.config/util37/templates/<workspace>/<report>.<format>.tmpl
+//	~/.config/util37/templates/<report>.<format>.tmpl
+//
+// falling back to the built-in template.
+func LoadTemplate(report, format, workspace, path string) (*template.Template, error) {
+	name := report + "." + format
+
+	var paths []string
+	if path != "" {
+		paths = []string{path}
+	} else {
+		paths = []string{
+			filepath.Join(TemplateDir(), workspace, name+".tmpl"),
+			filepath.Join(TemplateDir(), name+".tmpl"),
+		}
+	}
+
+	for _, p := range paths {
+		in, err := ioutil.ReadFile(p)
+		if err != nil {
+			if path == "" && os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		return NewTemplate(filepath.Base(p), string(in))
+	}
+
+	return NewTemplate(name, builtinTemplates[name])
+}
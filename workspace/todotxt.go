@@ -0,0 +1,297 @@
+package workspace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tasks are converted to todo.txt lines as follows:
+//
+//	priority   (A) for urgent, (B) for high and (D) for low; normal
+//	           tasks have no priority letter, and (C) or (E)-(Z) are
+//	           read as normal and low respectively
+//	tags       +tag, or @context for tags beginning with @; spaces
+//	           and percent signs in tags are percent-encoded
+//	dates      the creation date, preceded by the completion date for
+//	           completed tasks
+//	id:N       the task's ID
+//	due:D      the due date
+//	t:D        the day the task waits until (the threshold date)
+//	note:S     a note, with spaces and other special characters
+//	           percent-encoded; one for each note, in order
+//	pri:P      the priority letter of a completed task, or
+//	           pri:unknown for tasks of unknown priority
+//	cancelled  cancelled:yes for cancelled tasks
+//
+// Title words that would otherwise be read as a tag, a context or one
+// of these extensions, or that begin with a backslash, are escaped
+// with a leading backslash, as in "Email \+alice about \id:7".
+
+var (
+	todoPriRegexp  = regexp.MustCompile(`^\(([A-Z])\)$`)
+	todoDateRegexp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	todoExtRegexp  = regexp.MustCompile(`^([^\s:]+):(\S+)$`)
+)
+
+// todoKeys are the extensions that are read into a task's fields.
+var todoKeys = map[string]bool{
+	"id":        true,
+	"due":       true,
+	"t":         true,
+	"pri":       true,
+	"cancelled": true,
+	"note":      true,
+}
+
+// todoSpecial returns true if the title word would be read as
+// something other than part of the title.
+func todoSpecial(word string) bool {
+	switch {
+	case len(word) > 1 && (word[0] == '+' || word[0] == '@'):
+		return true
+	case strings.HasPrefix(word, `\`):
+		return true
+	case todoExtRegexp.MatchString(word):
+		return todoKeys[todoExtRegexp.FindStringSubmatch(word)[1]]
+	}
+	return false
+}
+
+func todoTitle(title string) string {
+	words := strings.Fields(title)
+	for i, word := range words {
+		if todoSpecial(word) {
+			words[i] = `\` + word
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+var todoTagEscaper = strings.NewReplacer("%", "%25", " ", "%20", "\t", "%09")
+
+func todoTag(tag string) string {
+	tag = todoTagEscaper.Replace(tag)
+	if strings.HasPrefix(tag, "@") {
+		return tag
+	}
+	return "+" + tag
+}
+
+// todoUntag decodes a tag; tags that aren't validly encoded, such as
+// "+50%" from another program, are kept as they are.
+func todoUntag(tag string) string {
+	if !strings.Contains(tag, "%") {
+		return tag
+	}
+
+	decoded, err := url.PathUnescape(tag)
+	if err != nil {
+		return tag
+	}
+	return decoded
+}
+
+var todoPriorities = map[Priority]string{
+	PriorityUrgent: "A",
+	PriorityHigh:   "B",
+	PriorityLow:    "D",
+}
+
+func todoPriority(letter string) Priority {
+	switch {
+	case letter == "A":
+		return PriorityUrgent
+	case letter == "B":
+		return PriorityHigh
+	case letter == "C":
+		return PriorityNormal
+	default:
+		return PriorityLow
+	}
+}
+
+func todoDate(s string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation(DateFormat, s, loc)
+}
+
+// TodoTxt returns the task as a todo.txt line.
+func (t *Task) TodoTxt() string {
+	var words []string
+	letter := todoPriorities[t.Priority]
+
+	if t.Done {
+		words = append(words, "x", t.Finished.Format(DateFormat))
+	} else if letter != "" {
+		words = append(words, "("+letter+")")
+	}
+	words = append(words, t.Created.Format(DateFormat), todoTitle(t.Title))
+
+	for _, tag := range t.Tags {
+		words = append(words, todoTag(tag))
+	}
+
+	words = append(words, fmt.Sprintf("id:%d", t.ID))
+	if !t.Due.IsZero() {
+		words = append(words, "due:"+t.Due.Format(DateFormat))
+	}
+
+	if !t.Wait.IsZero() {
+		words = append(words, "t:"+t.Wait.Format(DateFormat))
+	}
+
+	if t.Priority == PriorityUnknown {
+		words = append(words, "pri:"+priorityNames[PriorityUnknown])
+	} else if t.Done && letter != "" {
+		words = append(words, "pri:"+letter)
+	}
+
+	if t.Cancelled {
+		words = append(words, "cancelled:yes")
+	}
+
+	for _, note := range t.Notes {
+		words = append(words, "note:"+url.PathEscape(note))
+	}
+
+	return strings.Join(words, " ")
+}
+
+// ParseTodoTxt parses a todo.txt line, reading its dates in the
+// workspace's time zone. Tasks without an id: extension have an ID of
+// zero, and those without a creation date are created now.
+func (ws *Workspace) ParseTodoTxt(line string) (*Task, error) {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return nil, errors.New("workspace: empty todo.txt line")
+	}
+
+	t := &Task{Priority: PriorityNormal}
+	if words[0] == "x" {
+		t.Done = true
+		words = words[1:]
+		if len(words) > 0 && todoDateRegexp.MatchString(words[0]) {
+			finished, err := todoDate(words[0], ws.Location())
+			if err != nil {
+				return nil, err
+			}
+			t.Finished = finished
+			words = words[1:]
+		} else {
+			t.Finished = time.Now()
+		}
+	} else if len(words) > 0 && todoPriRegexp.MatchString(words[0]) {
+		t.Priority = todoPriority(todoPriRegexp.FindStringSubmatch(words[0])[1])
+		words = words[1:]
+	}
+
+	t.Created = time.Now()
+	if len(words) > 0 && todoDateRegexp.MatchString(words[0]) {
+		created, err := todoDate(words[0], ws.Location())
+		if err != nil {
+			return nil, err
+		}
+		t.Created = created
+		words = words[1:]
+	}
+
+	var title []string
+	for _, word := range words {
+		switch {
+		case strings.HasPrefix(word, `\`):
+			title = append(title, word[1:])
+		case len(word) > 1 && word[0] == '+':
+			t.Tags = append(t.Tags, todoUntag(word[1:]))
+		case len(word) > 1 && word[0] == '@':
+			t.Tags = append(t.Tags, todoUntag(word))
+		case todoExtRegexp.MatchString(word):
+			known, err := t.todoExtension(word, ws.Location())
+			if err != nil {
+				return nil, err
+			} else if !known {
+				title = append(title, word)
+			}
+		default:
+			title = append(title, word)
+		}
+	}
+
+	t.Title = strings.Join(title, " ")
+	if t.Title == "" {
+		return nil, errors.New("workspace: todo.txt task has no title")
+	}
+	return t, nil
+}
+
+// todoExtension applies a key:value extension to the task, returning
+// false if the key isn't known, in which case the word is part of the
+// title.
+func (t *Task) todoExtension(word string, loc *time.Location) (bool, error) {
+	subs := todoExtRegexp.FindStringSubmatch(word)
+	key, value := subs[1], subs[2]
+
+	var err error
+	switch key {
+	case "id":
+		t.ID, err = strconv.ParseUint(value, 10, 64)
+	case "due":
+		t.Due, err = todoDate(value, loc)
+	case "t":
+		t.Wait, err = todoDate(value, loc)
+	case "pri":
+		if value == priorityNames[PriorityUnknown] {
+			t.Priority = PriorityUnknown
+		} else if t.Done {
+			t.Priority = todoPriority(value)
+		}
+	case "cancelled":
+		t.Cancelled = t.Done && value == "yes"
+	case "note":
+		var note string
+		note, err = url.PathUnescape(value)
+		t.Notes = append(t.Notes, note)
+	default:
+		return false, nil
+	}
+
+	if err != nil {
+		return true, errors.New("workspace: invalid todo.txt extension " + word)
+	}
+	return true, nil
+}
+
+// WriteTodoTxt writes the tasks in todo.txt format, one per line.
+func WriteTodoTxt(w io.Writer, tasks []*Task) error {
+	for _, task := range tasks {
+		if _, err := fmt.Fprintln(w, task.TodoTxt()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTodoTxt reads tasks in todo.txt format, skipping blank lines.
+func (ws *Workspace) ReadTodoTxt(r io.Reader) ([]*Task, error) {
+	var tasks []*Task
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		task, err := ws.ParseTodoTxt(line)
+		if err != nil {
+			return nil, fmt.Errorf("%v (line %d)", err, n)
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, scanner.Err()
+}
@@ -0,0 +1,102 @@
+package workspace
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func todoTestTask(id uint64, title string) *Task {
+	t := NewTask(id, title)
+	t.Created = time.Date(2015, 8, 1, 0, 0, 0, 0, time.Local)
+	return t
+}
+
+func TestTodoTxtRoundTrip(t *testing.T) {
+	done := todoTestTask(4, "Ship the release")
+	done.Done = true
+	done.Finished = time.Date(2015, 8, 3, 0, 0, 0, 0, time.Local)
+	done.Priority = PriorityHigh
+
+	cancelled := todoTestTask(5, "Check due:soon")
+	cancelled.Done = true
+	cancelled.Cancelled = true
+	cancelled.Finished = done.Finished
+
+	tagged := todoTestTask(6, "Sort out the tags")
+	tagged.Tags = []string{"@home", "client/acme", "long tag", "50%"}
+
+	unknown := todoTestTask(7, "Priority unknown")
+	unknown.Priority = PriorityUnknown
+
+	noted := todoTestTask(8, `Escape \backslashes`)
+	noted.Notes = []string{"A note: with +tags and id:3", "100% done"}
+	noted.Due = time.Date(2015, 8, 10, 0, 0, 0, 0, time.Local)
+	noted.Wait = time.Date(2015, 8, 5, 0, 0, 0, 0, time.Local)
+
+	tasks := []*Task{
+		todoTestTask(1, "Email +alice about id:7"),
+		todoTestTask(2, "Ask @bob"),
+		todoTestTask(3, "Read http://example.com and x:y"),
+		done, cancelled, tagged, unknown, noted,
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTodoTxt(&buf, tasks); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := NewWorkspace("test").ReadTodoTxt(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(read) != len(tasks) {
+		t.Fatalf("read %d tasks, want %d", len(read), len(tasks))
+	}
+
+	for i := range tasks {
+		if read[i].ID != tasks[i].ID || !same(read[i], tasks[i]) {
+			t.Errorf("task %d changed:\n\thave %+v\n\twant %+v\n\tline %s",
+				i, read[i], tasks[i], tasks[i].TodoTxt())
+		}
+	}
+}
+
+func TestParseTodoTxt(t *testing.T) {
+	task, err := NewWorkspace("test").ParseTodoTxt("(A) 2015-08-01 Call Mom +family @phone due:2015-08-02 +50%")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if task.Title != "Call Mom" {
+		t.Errorf("title is %q, want %q", task.Title, "Call Mom")
+	}
+
+	if task.Priority != PriorityUrgent {
+		t.Errorf("priority is %s, want %s", task.Priority, PriorityUrgent)
+	}
+
+	want := []string{"family", "@phone", "50%"}
+	if !sameStrings(task.Tags, want) {
+		t.Errorf("tags are %v, want %v", task.Tags, want)
+	}
+
+	if task.Due.Format(DateFormat) != "2015-08-02" {
+		t.Errorf("due date is %v, want 2015-08-02", task.Due)
+	}
+}
+
+func TestParseTodoTxtTimeZone(t *testing.T) {
+	ws := testWorkspace(t, 0)
+	task, err := ws.ParseTodoTxt("2015-08-01 Call Mom due:2015-08-02 t:2015-08-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, day := range map[string]time.Time{"due": task.Due, "wait": task.Wait} {
+		if got := ws.DayOf(day).Format(DateFormat); got != "2015-08-02" {
+			t.Errorf("%s day is %s, want 2015-08-02", name, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package workspace
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snooze sets the day before which the task won't be added to the
+// day's list. A task snoozed past today is removed from today's list;
+// one whose wait has ended is put back on it, and its wait is cleared.
+func (ws *Workspace) Snooze(id uint64, until time.Time) bool {
+	task, ok := ws.Tasks[id]
+	if !ok {
+		return false
+	}
+
+	today := ws.Today()
+	task.Wait = ws.Date(until)
+	if !task.Waiting(today) {
+		task.Wait = time.Time{}
+	}
+
+	e, ok := ws.Entries[ws.DateID(today)]
+	if !ok {
+		return true
+	}
+
+	i := e.Index(id)
+	if task.Waiting(today) && i != -1 {
+		e.Tasks = append(e.Tasks[:i:i], e.Tasks[i+1:]...)
+	} else if !task.Waiting(today) && !task.Done && i == -1 {
+		e.Tasks = append(e.Tasks, id)
+	}
+	return true
+}
+
+// WaitingFilter returns a filter selecting the unfinished tasks that
+// are waiting for a day after today, a workspace day.
+func WaitingFilter(today time.Time) Filter {
+	return func(ts TaskSet) TaskSet {
+		var tasks = TaskSet{}
+		for id, task := range ts {
+			if task.Waiting(today) {
+				tasks[id] = task
+			}
+		}
+		return tasks
+	}
+}
+
+// WaitingUntilFilter returns a filter selecting the unfinished tasks
+// that are waiting for a day after today, up to and including until.
+func WaitingUntilFilter(today, until time.Time) Filter {
+	return func(ts TaskSet) TaskSet {
+		var tasks = TaskSet{}
+		for id, task := range ts {
+			if task.Waiting(today) && !Day(task.Wait).After(Day(until)) {
+				tasks[id] = task
+			}
+		}
+		return tasks
+	}
+}
+
+var waitRegexp = regexp.MustCompile(`^\+?(\d+)([dwm]?)$`)
+
+// ParseWait parses the day a task should wait until. As well as the
+// forms accepted by ParseDay, it accepts "tomorrow", the name of a
+// weekday (the next one after today), or a number of days, weeks or
+// months from today, such as "3d", "+2w" or "1m"; a bare number is a
+// number of days.
+func (ws *Workspace) ParseWait(s string) (time.Time, error) {
+	s = strings.ToLower(s)
+	if s == "tomorrow" {
+		return ws.Today().AddDate(0, 0, 1), nil
+	}
+
+	if subs := waitRegexp.FindStringSubmatch(s); subs != nil {
+		n, err := strconv.Atoi(subs[1])
+		if err != nil {
+			return time.Time{}, errors.New("workspace: invalid day " + s)
+		}
+
+		switch subs[2] {
+		case "w":
+			return ws.Today().AddDate(0, 0, 7*n), nil
+		case "m":
+			return ws.Today().AddDate(0, n, 0), nil
+		default:
+			return ws.Today().AddDate(0, 0, n), nil
+		}
+	}
+
+	if len(s) >= 3 {
+		for wd := time.Sunday; wd <= time.Saturday; wd++ {
+			if strings.HasPrefix(strings.ToLower(wd.String()), s) {
+				today := ws.Today()
+				n := (int(wd) - int(today.Weekday()) + 6) % 7
+				return today.AddDate(0, 0, n+1), nil
+			}
+		}
+	}
+
+	return ws.ParseDay(s)
+}
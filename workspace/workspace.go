@@ -9,13 +9,62 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// An Entry contains a set of tasks for the day.
+// An Entry contains a set of tasks for the day. The order of Tasks is
+// the user's ordering for the day, and is carried over to the next
+// entry.
 type Entry struct {
 	Date  time.Time
 	Tasks []uint64
+
+	// Focus is the number of unfinished tasks at the top of the
+	// entry that are the focus for the day.
+	Focus int `json:",omitempty"`
+
+	// Backfilled is set for entries created for days on which the
+	// workspace wasn't used.
+	Backfilled bool `json:",omitempty"`
+}
+
+// Index returns the position of the task in the entry, or -1 if it
+// isn't in the entry.
+func (e *Entry) Index(id uint64) int {
+	for i := range e.Tasks {
+		if e.Tasks[i] == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Move moves the task to position pos in the entry, shifting the
+// tasks in between. The position is clamped to the entry's bounds.
+func (e *Entry) Move(id uint64, pos int) bool {
+	i := e.Index(id)
+	if i == -1 {
+		return false
+	}
+
+	if pos < 0 {
+		pos = 0
+	} else if pos >= len(e.Tasks) {
+		pos = len(e.Tasks) - 1
+	}
+
+	rest := make([]uint64, 0, len(e.Tasks)-1)
+	rest = append(rest, e.Tasks[:i]...)
+	rest = append(rest, e.Tasks[i+1:]...)
+
+	tasks := make([]uint64, 0, len(e.Tasks))
+	tasks = append(tasks, rest[:pos]...)
+	tasks = append(tasks, id)
+	tasks = append(tasks, rest[pos:]...)
+	e.Tasks = tasks
+	return true
 }
 
 // A Workspace is a container for a set of entries and tasks. A
@@ -33,13 +82,36 @@ type Workspace struct {
 	Tasks TaskSet
 
 	Tags map[string][]uint64
+
+	// Queries contains the saved queries for this workspace.
+	Queries Queries
+
+	// DayStart is the hour at which the workspace's day starts;
+	// work done before then belongs to the previous day.
+	DayStart int
+
+	// TimeZone is the name of the time zone that days are counted
+	// in; if empty, the local time zone is used.
+	TimeZone string
+
+	// Backfill is set if entries should be created for days on
+	// which the workspace wasn't used, so that the history shows
+	// the tasks carried through them.
+	Backfill bool
+
+	loc  *time.Location
+	file os.FileInfo // the file the workspace was read from
 }
 
 func (w *Workspace) compensate() *jWorkspace {
 	jw := &jWorkspace{
-		Name: w.Name,
-		Last: w.Last,
-		Tags: w.Tags,
+		Name:     w.Name,
+		Last:     w.Last,
+		Tags:     w.Tags,
+		Queries:  w.Queries,
+		DayStart: w.DayStart,
+		TimeZone: w.TimeZone,
+		Backfill: w.Backfill,
 	}
 
 	jw.Entries = map[string]*Entry{}
@@ -62,12 +134,21 @@ type jWorkspace struct {
 	Entries map[string]*Entry
 	Tasks   map[string]*Task
 	Tags    map[string][]uint64
+	Queries Queries `json:",omitempty"`
+
+	DayStart int    `json:",omitempty"`
+	TimeZone string `json:",omitempty"`
+	Backfill bool   `json:",omitempty"`
 }
 
 func (jw *jWorkspace) rectify(w *Workspace) error {
 	w.Name = jw.Name
 	w.Last = jw.Last
 	w.Tags = jw.Tags
+	w.Queries = jw.Queries
+	w.DayStart = jw.DayStart
+	w.TimeZone = jw.TimeZone
+	w.Backfill = jw.Backfill
 
 	w.Entries = map[uint64]*Entry{}
 	for k, v := range jw.Entries {
@@ -99,7 +180,8 @@ func NewWorkspace(name string) *Workspace {
 	}
 }
 
-// EntryTasks returns a set of tasks for an entry.
+// EntryTasks returns a set of tasks for an entry. Tasks listed in the
+// entry that are no longer in the workspace are skipped.
 func (ws *Workspace) EntryTasks(id uint64) TaskSet {
 	e, ok := ws.Entries[id]
 	if !ok {
@@ -108,72 +190,198 @@ func (ws *Workspace) EntryTasks(id uint64) TaskSet {
 
 	var tasks = TaskSet{}
 	for _, id := range e.Tasks {
-		tasks[id] = ws.Tasks[id]
+		if task, ok := ws.Tasks[id]; ok {
+			tasks[id] = task
+		}
 	}
 
 	return tasks
 }
 
-// NewEntry returns an entry for today; if none exists, a new one is
-// created and initialised with the set of unfinished tasks from the
-// previous entry.
+// FocusTasks returns the tasks that are the focus for an entry: the
+// first Focus unfinished tasks, in the entry's order.
+func (ws *Workspace) FocusTasks(id uint64) []*Task {
+	e, ok := ws.Entries[id]
+	if !ok {
+		return nil
+	}
+
+	var tasks []*Task
+	for _, tid := range e.Tasks {
+		if len(tasks) >= e.Focus {
+			break
+		}
+
+		task := ws.Tasks[tid]
+		if task != nil && !task.Done {
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks
+}
+
+// carry returns the tasks on the list prev that carry over to the
+// list for day: those that weren't finished before the day started and
+// aren't waiting for a later day.
+func (ws *Workspace) carry(prev *Entry, day time.Time) []uint64 {
+	var tasks = make([]uint64, 0, len(prev.Tasks))
+	for _, tid := range prev.Tasks {
+		task := ws.Tasks[tid]
+		if task == nil || task.Waiting(day) {
+			continue
+		}
+
+		if task.Done && task.Finished.Before(ws.DayBegin(day)) {
+			continue
+		}
+		tasks = append(tasks, tid)
+	}
+	return tasks
+}
+
+// reconcile adds to the entry any unfinished task that isn't waiting
+// and isn't already on it, such as a task whose wait has ended or one
+// added to an older entry after this one was created. The waits that
+// have ended are cleared.
+func (ws *Workspace) reconcile(e *Entry, day time.Time) {
+	for _, task := range ws.Tasks.Sort() {
+		if task.Done || task.Waiting(day) {
+			continue
+		}
+
+		task.Wait = time.Time{}
+		if e.Index(task.ID) == -1 {
+			e.Tasks = append(e.Tasks, task.ID)
+		}
+	}
+}
+
+// NewEntry returns the entry for today, creating it if needed. A new
+// entry starts with the tasks carried over from the most recent
+// entry, keeping their order and the focus count; if the workspace
+// backfills, entries are also created for any days skipped since
+// then. Every unfinished task that isn't waiting for a later day is
+// added to today's entry.
 func (ws *Workspace) NewEntry() uint64 {
-	id := uint64(Today().Unix())
+	today := ws.Today()
+	id := ws.DateID(today)
 
 	e := ws.Entries[id]
 	if e == nil {
-		e := &Entry{
-			Date: time.Now(),
-		}
+		e = &Entry{Date: today}
 
-		if len(ws.Entries) != 0 {
-			tasks := ws.EntryTasks(ws.Last)
-			tasks = tasks.Unfinished()
-			e.Tasks = make([]uint64, 0, len(tasks))
-			for id := range tasks {
-				e.Tasks = append(e.Tasks, id)
+		if lastID, ok := ws.AdjacentEntry(id, -1); ok {
+			last := ws.Entries[lastID]
+			if ws.Backfill {
+				last = ws.backfill(lastID, today)
 			}
+			e.Tasks = ws.carry(last, today)
+			e.Focus = last.Focus
 		}
 
-		ws.Last = id
 		ws.Entries[id] = e
+		if id > ws.Last {
+			ws.Last = id
+		}
 	}
 
+	ws.reconcile(e, today)
 	return id
 }
 
-// Tag adds a tag to the specified task.
+// backfill creates entries for the days after the entry lastID and
+// before today, returning the last entry.
+func (ws *Workspace) backfill(lastID uint64, today time.Time) *Entry {
+	last := ws.Entries[lastID]
+	day := ws.EntryDay(lastID)
+	for {
+		day = day.AddDate(0, 0, 1)
+		if !day.Before(today) {
+			return last
+		}
+
+		if e, ok := ws.Entries[ws.DateID(day)]; ok {
+			last = e
+			continue
+		}
+
+		e := &Entry{
+			Date:       day,
+			Tasks:      ws.carry(last, day),
+			Focus:      last.Focus,
+			Backfilled: true,
+		}
+		ws.Entries[ws.DateID(day)] = e
+		last = e
+	}
+}
+
+// Tag adds a tag to the specified task, updating both the task and
+// the tag index.
 func (ws *Workspace) Tag(id uint64, tag string) bool {
 	task, ok := ws.Tasks[id]
 	if !ok {
 		return false
 	}
 
-	for i := range task.Tags {
-		if task.Tags[i] == tag {
+	if !contains(tag, task.Tags) {
+		task.Tags = append(task.Tags, tag)
+		sort.Strings(task.Tags)
+	}
+
+	if ws.Tags == nil {
+		ws.Tags = map[string][]uint64{}
+	}
+
+	tags := ws.Tags[tag]
+	for i := range tags {
+		if tags[i] == id {
 			return true
 		}
 	}
+	ws.Tags[tag] = append(tags, id)
 
-	tags, ok := ws.Tags[tag]
-	if ok {
-		for i := range tags {
-			if tags[i] == id {
-				return true
+	return true
+}
+
+// CheckEntries returns a problem for each task listed in an entry that
+// doesn't exist in the workspace.
+func (ws *Workspace) CheckEntries() []Problem {
+	var problems []Problem
+
+	var ids = make([]uint64, 0, len(ws.Entries))
+	for id := range ws.Entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		e := ws.Entries[id]
+		for _, tid := range e.Tasks {
+			if ws.Tasks[tid] == nil {
+				problems = append(problems, Problem{ID: tid,
+					Description: "orphaned: listed in the entry for " +
+						e.Date.Format(DateFormat) +
+						" but the task doesn't exist"})
 			}
 		}
 	}
 
-	tags = append(tags, id)
-	if ws.Tags == nil {
-		ws.Tags = map[string][]uint64{}
-	}
-	ws.Tags[tag] = tags
+	return problems
+}
 
-	task.Tags = append(task.Tags, tag)
-	sort.Strings(task.Tags)
-	ws.Tasks[id] = task
-	return true
+// PruneEntries removes tasks that don't exist from every entry.
+func (ws *Workspace) PruneEntries() {
+	for _, e := range ws.Entries {
+		var tasks = make([]uint64, 0, len(e.Tasks))
+		for _, tid := range e.Tasks {
+			if ws.Tasks[tid] != nil {
+				tasks = append(tasks, tid)
+			}
+		}
+		e.Tasks = tasks
+	}
 }
 
 // FileName returns the workspace's filename.
@@ -183,6 +391,32 @@ func (ws *Workspace) FileName() string {
 
 const configDirName = "utility37"
 
+// ValidName returns true if name can be used as a workspace name: it
+// must not be empty, start with a dot, or contain a path separator.
+func ValidName(name string) bool {
+	return name != "" && name[0] != '.' && !strings.ContainsAny(name, `/\`)
+}
+
+// ListWorkspaces returns the names of the existing workspaces, in
+// order.
+func ListWorkspaces() ([]string, error) {
+	paths, err := filepath.Glob(FileName("*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		if ValidName(name) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
 // FileName returns the name for a workspace file.
 func FileName(name string) string {
 	basePath := os.Getenv("HOME")
@@ -221,8 +455,7 @@ func Unmarshal(in []byte, ws *Workspace) error {
 // ReadFile reads the named workspace from disk. If it doesn't exist,
 // and init is true, a new workspace will be created.
 func ReadFile(name string, init bool) (*Workspace, error) {
-	path := FileName(name)
-	in, err := ioutil.ReadFile(path)
+	f, err := os.Open(FileName(name))
 	if err != nil {
 		if init && os.IsNotExist(err) {
 			return NewWorkspace(name), nil
@@ -230,6 +463,19 @@ func ReadFile(name string, init bool) (*Workspace, error) {
 
 		return nil, err
 	}
+	defer f.Close()
+
+	// The file is replaced rather than changed when it is written,
+	// so it is the one read as long as it is open.
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
 
 	var ws Workspace
 	err = Unmarshal(in, &ws)
@@ -237,10 +483,13 @@ func ReadFile(name string, init bool) (*Workspace, error) {
 		return nil, err
 	}
 
+	ws.file = fi
 	return &ws, nil
 }
 
-// WriteFile stores the workspace to disk.
+// WriteFile stores the workspace to disk. It holds the workspace's
+// lock while writing, and returns ErrChanged without writing if
+// another program has written the file since the workspace was read.
 func WriteFile(ws *Workspace) error {
 	out, err := Marshal(ws)
 	if err != nil {
@@ -259,5 +508,40 @@ func WriteFile(ws *Workspace) error {
 		return err
 	}
 
-	return ioutil.WriteFile(ws.FileName(), out, 0600)
+	unlock, err := lock(ws.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fi, err := os.Stat(name)
+	if err == nil && !sameFile(ws.file, fi) {
+		return ErrChanged
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// The file is replaced in one step, so that anything reading it
+	// at the same time never sees it half written.
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+ws.Name+".")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(out)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	ws.file, err = os.Stat(name)
+	return err
 }